@@ -1,5 +1,8 @@
 // Package awspolicy contains functions to compare structural equivalence
 // of AWS IAM policies.
+//
+// All exported functions in this package are safe for concurrent use by
+// multiple goroutines.
 package awspolicy
 
 // This Source Code Form is subject to the terms of the Mozilla Public
@@ -7,17 +10,12 @@ package awspolicy
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/aws/aws-sdk-go-v2/aws/arn"
-	"github.com/mitchellh/mapstructure"
 )
 
 // PoliciesAreEquivalent tests for the structural equivalence of two
@@ -25,107 +23,95 @@ import (
 // single element string arrays as equivalent to a string without an
 // array, as the AWS endpoints do.
 //
-// It will, however, detect reordering and ignore whitespace.
+// It will, however, detect reordering and ignore whitespace. Values that
+// are objects or nested arrays, which AWS accepts in some condition
+// values, are compared deeply, ignoring the order of keys and elements.
+//
+// Options may be supplied to relax the comparison, for example to treat
+// policies deployed to different accounts, partitions or regions as
+// equivalent.
 //
 // Returns true if the policies are structurally equivalent, false
 // otherwise. If either of the input strings are not valid JSON,
 // false is returned along with an error.
-func PoliciesAreEquivalent(policy1, policy2 string) (bool, error) {
-	// Although "policy" generally equates to JSON, AWS also has pseudo-JSON
-	// policies, such as assume-role policies that can be lists of JSONs. This
-	// only handles a one-length list of JSON:
-	policy1 = strings.TrimSpace(policy1)
-	if strings.HasPrefix(policy1, "[") && strings.HasSuffix(policy1, "]") {
-		policy1 = strings.TrimPrefix(strings.TrimSuffix(policy1, "]"), "[")
-		policy1 = strings.TrimSpace(policy1)
-	}
-	if policy1 == "" {
-		policy1 = "{}"
-	}
-
-	policy2 = strings.TrimSpace(policy2)
-	if strings.HasPrefix(policy2, "[") && strings.HasSuffix(policy2, "]") {
-		policy2 = strings.TrimPrefix(strings.TrimSuffix(policy2, "]"), "[")
-		policy2 = strings.TrimSpace(policy2)
-	}
-	if policy2 == "" {
-		policy2 = "{}"
+//
+// PoliciesAreEquivalent is safe for concurrent use by multiple goroutines.
+// It keeps no state between calls and never modifies shared data, so the
+// same Options may be passed to concurrent calls.
+func PoliciesAreEquivalent(policy1, policy2 string, opts ...Option) (bool, error) {
+	o := newOptions(opts)
+	if o.urlDecode {
+		var err error
+		if policy1, err = DecodePolicyDocument(policy1); err != nil {
+			return false, fmt.Errorf("decoding policy 1: %w", err)
+		}
+		if policy2, err = DecodePolicyDocument(policy2); err != nil {
+			return false, fmt.Errorf("decoding policy 2: %w", err)
+		}
 	}
 
-	policy1intermediate := &intermediatePolicyDocument{}
-	if err := json.Unmarshal([]byte(policy1), policy1intermediate); err != nil {
-		return false, fmt.Errorf("unmarshaling policy 1: %s", err)
+	policy1Doc, err1 := parsePolicy(policy1)
+	policy2Doc, err2 := parsePolicy(policy2)
+	if err := policyError(1, err1); err != nil {
+		return false, err
 	}
-
-	policy2intermediate := &intermediatePolicyDocument{}
-	if err := json.Unmarshal([]byte(policy2), policy2intermediate); err != nil {
-		return false, fmt.Errorf("unmarshaling policy 2: %s", err)
+	if err := policyError(2, err2); err != nil {
+		return false, err
 	}
 
-	if reflect.DeepEqual(policy1intermediate, policy2intermediate) {
-		return true, nil
+	// A document that is valid JSON but can't be decoded as a policy is
+	// still equivalent to an identical copy of itself.
+	if err1 != nil || err2 != nil {
+		if jsonEqual(policy1, policy2) {
+			return true, nil
+		}
+		if err1 != nil {
+			return false, fmt.Errorf("parsing policy 1: %w", err1)
+		}
+		return false, fmt.Errorf("parsing policy 2: %w", err2)
 	}
 
-	policy1Doc, err := policy1intermediate.document()
-	if err != nil {
-		return false, fmt.Errorf("parsing policy 1: %s", err)
-	}
-	policy2Doc, err := policy2intermediate.document()
-	if err != nil {
-		return false, fmt.Errorf("parsing policy 2: %s", err)
-	}
+	policy1Doc = o.substitutions.apply(policy1Doc)
+	policy2Doc = o.substitutions.apply(policy2Doc)
 
 	return policy1Doc.equals(policy2Doc), nil
 }
 
-type intermediatePolicyDocument struct {
-	Version    string      `json:",omitempty"`
-	Id         string      `json:",omitempty"`
-	Statements interface{} `json:"Statement"`
-}
-
-func (intermediate *intermediatePolicyDocument) document() (*policyDocument, error) {
-	var statements []*policyStatement
-
-	// Decode only non-nil statements to prevent irreversible result when setting values
-	// in Terraform state.
-	// Reference: https://github.com/hashicorp/terraform-provider-aws/issues/22944
-	if intermediate.Statements != nil {
-		switch s := intermediate.Statements.(type) {
-		case []interface{}:
-			if err := mapstructure.Decode(s, &statements); err != nil {
-				return nil, fmt.Errorf("parsing statement 1: %s", err)
-			}
-		case map[string]interface{}:
-			var singleStatement *policyStatement
-			if err := mapstructure.Decode(s, &singleStatement); err != nil {
-				return nil, fmt.Errorf("parsing statement 2: %s", err)
-			}
-			statements = append(statements, singleStatement)
-		default:
-			return nil, errors.New("unknown statement parsing problem")
-		}
-	}
-
-	document := &policyDocument{
-		Version:    intermediate.Version,
-		Id:         intermediate.Id,
-		Statements: statements,
+// policyError returns an error for a policy that is not valid JSON.
+func policyError(n int, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && parseErr.Syntax {
+		return fmt.Errorf("unmarshaling policy %d: %w", n, err)
 	}
-
-	return document, nil
+	return nil
 }
 
+var (
+	accountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+	regionRegex    = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+$`)
+	numberRegex    = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
 type policyDocument struct {
 	Version    string
 	Id         string
 	Statements []*policyStatement
+
+	// SingleStatement is true if Statement was written as a single
+	// statement object rather than an array.
+	SingleStatement bool
+
+	// Members holds the names of the document's members in the order they
+	// were written, and Unknown the source text of members that aren't
+	// parsed, so that PreserveShape can write the document back as it was.
+	Members []string
+	Unknown map[string]string
 }
 
 func (doc *policyDocument) equals(other *policyDocument) bool {
 	// Prevent panic
-	if doc == nil {
-		return other == nil
+	if doc == nil || other == nil {
+		return doc == other
 	}
 	// Check the basic fields of the document
 	if doc.Version != other.Version {
@@ -141,366 +127,271 @@ func (doc *policyDocument) equals(other *policyDocument) bool {
 		return false
 	}
 
-	// If we have the same number of statements in the policy, does
-	// each statement in the intermediate have a corresponding statement in
-	// other which is equal? If no, policies are not equal, if yes,
-	// then they may be.
+	// Each statement is normalized once into a canonical key, and the
+	// statements are then matched as a multiset of keys: every statement in
+	// other must use up one equal statement in doc. The key leaves out the
+	// partition of root user ARNs, so statements with equal keys are paired
+	// up by matchRoots.
+	groups := make(map[string][]rootPrincipals, len(doc.Statements))
 	for _, ours := range doc.Statements {
-		found := false
-		for _, theirs := range other.Statements {
-			if ours.equals(theirs) {
-				found = true
-			}
-		}
-
-		if !found {
-			return false
-		}
+		key := ours.canonicalKey()
+		groups[key] = append(groups[key], ours.rootPrincipals())
 	}
 
-	// Now we need to repeat this process the other way around to
-	// ensure we don't have any matching errors.
+	theirGroups := make(map[string][]rootPrincipals, len(other.Statements))
 	for _, theirs := range other.Statements {
-		found := false
-		for _, ours := range doc.Statements {
-			if theirs.equals(ours) {
-				found = true
-			}
+		key := theirs.canonicalKey()
+		if len(theirGroups[key]) == len(groups[key]) {
+			return false
 		}
+		theirGroups[key] = append(theirGroups[key], theirs.rootPrincipals())
+	}
 
-		if !found {
+	for key, ours := range groups {
+		if !matchRoots(ours, theirGroups[key]) {
 			return false
 		}
 	}
-
 	return true
 }
 
 type policyStatement struct {
-	Sid           string                            `json:",omitempty" mapstructure:"Sid"`
-	Effect        string                            `json:",omitempty" mapstructure:"Effect"`
-	Actions       interface{}                       `json:"Action,omitempty" mapstructure:"Action"`
-	NotActions    interface{}                       `json:"NotAction,omitempty" mapstructure:"NotAction"`
-	Resources     interface{}                       `json:"Resource,omitempty" mapstructure:"Resource"`
-	NotResources  interface{}                       `json:"NotResource,omitempty" mapstructure:"NotResource"`
-	Principals    interface{}                       `json:"Principal,omitempty" mapstructure:"Principal"`
-	NotPrincipals interface{}                       `json:"NotPrincipal,omitempty" mapstructure:"NotPrincipal"`
-	Conditions    map[string]map[string]interface{} `json:"Condition,omitempty" mapstructure:"Condition"`
+	Sid           string
+	Effect        string
+	Actions       valueList
+	NotActions    valueList
+	Resources     valueList
+	NotResources  valueList
+	Principals    *principalsBlock
+	NotPrincipals *principalsBlock
+	Conditions    conditionsBlock
+
+	// Members and Unknown are kept as for policyDocument.
+	Members []string
+	Unknown map[string]string
 }
 
 func (statement *policyStatement) equals(other *policyStatement) bool {
-	if statement.Sid != other.Sid {
-		return false
-	}
-
-	if !strings.EqualFold(statement.Effect, other.Effect) {
-		return false
-	}
-
-	ourActions := newStringSet(statement.Actions)
-	theirActions := newStringSet(other.Actions)
-	if !stringSlicesEqualIgnoreOrder(ourActions, theirActions) {
-		return false
-	}
-
-	ourNotActions := newStringSet(statement.NotActions)
-	theirNotActions := newStringSet(other.NotActions)
-	if !stringSlicesEqualIgnoreOrder(ourNotActions, theirNotActions) {
-		return false
-	}
-
-	ourResources := newStringSet(statement.Resources)
-	theirResources := newStringSet(other.Resources)
-	//if !stringSlicesEqualIgnoreOrder(ourResources, theirResources) {
-	if !stringSlicesEqualIgnoreOrder(ourResources, theirResources) {
-		//	fmt.Printf("%v\n%v\n", ourResources, theirResources)
-		return false
-	}
-	//if !ourResources.equals(theirResources) {
-	//	return false
-	//}
-
-	ourNotResources := newStringSet(statement.NotResources)
-	theirNotResources := newStringSet(other.NotResources)
-	if !stringSlicesEqualIgnoreOrder(ourNotResources, theirNotResources) {
-		//if !ourNotResources.equals(theirNotResources) {
-		return false
-	}
-
-	ourConditionsBlock := conditionsBlock(statement.Conditions)
-	theirConditionsBlock := conditionsBlock(other.Conditions)
-	if !ourConditionsBlock.Equals(theirConditionsBlock) {
-		return false
-	}
-
-	if statement.Principals != nil || other.Principals != nil {
-		stringPrincipalsEqual := stringPrincipalsEqual(statement.Principals, other.Principals)
-		mapPrincipalsEqual := mapPrincipalsEqual(statement.Principals, other.Principals)
-		if !(stringPrincipalsEqual || mapPrincipalsEqual) {
-			return false
-		}
-	}
+	return statement.canonicalKey() == other.canonicalKey() &&
+		statement.rootPrincipals().match(other.rootPrincipals())
+}
 
-	if statement.NotPrincipals != nil || other.NotPrincipals != nil {
-		stringNotPrincipalsEqual := stringPrincipalsEqual(statement.NotPrincipals, other.NotPrincipals)
-		mapNotPrincipalsEqual := mapPrincipalsEqual(statement.NotPrincipals, other.NotPrincipals)
-		if !(stringNotPrincipalsEqual || mapNotPrincipalsEqual) {
-			return false
-		}
-	}
+// principalsBlock is a Principal or NotPrincipal element, mapping
+// principal types to principals. AWS stores "Principal": "*" as
+// "Principal": {"AWS": "*"}, so a string principal is decoded as an AWS
+// principal with Bare set.
+type principalsBlock struct {
+	Types map[string]valueList
+	Bare  bool
+}
 
-	return true
+// canonicalServicePrincipal returns the form of a service principal that
+// is compared. AWS may return the China partition form
+// (ec2.amazonaws.com.cn) of the principal that was submitted, which is
+// reduced to the amazonaws.com form. Regional forms
+// (logs.us-east-1.amazonaws.com) name a different principal and are
+// compared as written.
+func canonicalServicePrincipal(principal string) string {
+	if service, ok := strings.CutSuffix(principal, ".amazonaws.com.cn"); ok {
+		return service + ".amazonaws.com"
+	}
+	return principal
 }
 
-func mapPrincipalsEqual(ours, theirs interface{}) bool {
-	ourPrincipalMap, oursOk := ours.(map[string]interface{})
-	theirPrincipalMap, theirsOk := theirs.(map[string]interface{})
+type conditionsBlock map[string]map[string]valueList
 
-	oursNormalized := make(map[string]principalStringSet)
-	if oursOk {
-		for key, val := range ourPrincipalMap {
-			var tmp = newPrincipalStringSet(val)
-			if len(tmp) > 0 {
-				oursNormalized[key] = tmp
+// normalize converts the condition values to stringSets. AWS drops
+// condition keys with no values and operators with no keys, so those are
+// removed the same way missing and empty Principal sets are treated alike.
+func (conditions conditionsBlock) normalize() map[string]map[string]stringSet {
+	normalized := make(map[string]map[string]stringSet)
+	for key, condition := range conditions {
+		normalizedCondition := make(map[string]stringSet)
+		for innerKey, val := range condition {
+			set := val.set()
+			if len(set) == 0 {
+				continue
 			}
+			normalizedCondition[innerKey] = set
 		}
-	}
-
-	theirsNormalized := make(map[string]principalStringSet)
-	if theirsOk {
-		for key, val := range theirPrincipalMap {
-			var tmp = newPrincipalStringSet(val)
-			if len(tmp) > 0 {
-				theirsNormalized[key] = newPrincipalStringSet(val)
-			}
+		if len(normalizedCondition) > 0 {
+			normalized[key] = normalizedCondition
 		}
 	}
+	return normalized
+}
 
-	for key, ours := range oursNormalized {
-		theirs, ok := theirsNormalized[key]
-		if !ok {
-			return false
-		}
+// valueList is a policy element that AWS accepts either as a single value
+// or as an array of values, such as Action or a condition's values.
+type valueList struct {
+	// Values holds the contents of strings, the literal text of numbers
+	// and booleans, and the source text of structured values.
+	Values []string
 
-		if !ours.equals(theirs) {
-			return false
-		}
-	}
+	// Kinds holds the JSON type of each value. It is nil if every value
+	// is a string.
+	Kinds []valueKind
 
-	for key, theirs := range theirsNormalized {
-		ours, ok := oursNormalized[key]
-		if !ok {
-			return false
-		}
-
-		if !theirs.equals(ours) {
-			return false
-		}
-	}
-
-	return true
+	// Single is true if the value was written without an array.
+	Single bool
 }
 
-func stringPrincipalsEqual(ours, theirs interface{}) bool {
-	ourPrincipal, oursIsString := ours.(string)
-	theirPrincipal, theirsIsString := theirs.(string)
-
-	if !(oursIsString && theirsIsString) {
-		return false
-	}
-
-	if ourPrincipal == theirPrincipal {
-		return true
-	}
+type valueKind uint8
 
-	// Handle AWS converting account ID principal to root IAM user ARN
-	// ACCOUNTID == arn:PARTITION:iam::ACCOUNTID:root
-	accountIDRegex := regexp.MustCompile(`^[0-9]{12}$`)
+const (
+	stringValue valueKind = iota
+	numberValue
+	boolValue
 
-	if accountIDRegex.MatchString(ourPrincipal) {
-		if theirArn, err := arn.Parse(theirPrincipal); err == nil {
-			if theirArn.Service == "iam" && theirArn.Resource == "root" && theirArn.AccountID == ourPrincipal {
-				return true
-			}
-		}
-	}
+	// structuredValue is an object, an array nested in the list or a null
+	// within the list. Structured values are compared deeply, ignoring
+	// the order of object keys and of array elements.
+	structuredValue
+)
 
-	if accountIDRegex.MatchString(theirPrincipal) {
-		if ourArn, err := arn.Parse(ourPrincipal); err == nil {
-			if ourArn.Service == "iam" && ourArn.Resource == "root" && ourArn.AccountID == theirPrincipal {
-				return true
-			}
-		}
+// kind returns the JSON type of the value at index i.
+func (values valueList) kind(i int) valueKind {
+	if values.Kinds == nil {
+		return stringValue
 	}
-
-	return false
+	return values.Kinds[i]
 }
 
-type conditionsBlock map[string]map[string]interface{}
-
-func (conditions conditionsBlock) Equals(other conditionsBlock) bool {
-	if conditions == nil && other != nil || other == nil && conditions != nil {
-		return false
+// setKind records the JSON type of the value at index i, allocating Kinds
+// only once a value that isn't a string is seen.
+func (values *valueList) setKind(i int, kind valueKind) {
+	if kind == stringValue && values.Kinds == nil {
+		return
 	}
-
-	if len(conditions) != len(other) {
-		return false
+	for len(values.Kinds) <= i {
+		values.Kinds = append(values.Kinds, stringValue)
 	}
+	values.Kinds[i] = kind
+}
 
-	oursNormalized := make(map[string]map[string]stringSet)
-	for key, condition := range conditions {
-		normalizedCondition := make(map[string]stringSet)
-		for innerKey, val := range condition {
-			normalizedCondition[innerKey] = newStringSet(val)
-		}
-		oursNormalized[key] = normalizedCondition
-	}
+type stringSet []string
 
-	theirsNormalized := make(map[string]map[string]stringSet)
-	for key, condition := range other {
-		normalizedCondition := make(map[string]stringSet)
-		for innerKey, val := range condition {
-			normalizedCondition[innerKey] = newStringSet(val)
-		}
-		theirsNormalized[key] = normalizedCondition
+// set returns the values as a stringSet. An absent or empty list is an
+// empty stringSet. Numbers are written in a canonical form so that they
+// compare by exact decimal value, and structured values in a canonical
+// form that ignores ordering.
+func (values valueList) set() stringSet {
+	if values.Values == nil {
+		return stringSet{}
 	}
-
-	for key, ours := range oursNormalized {
-		theirs, ok := theirsNormalized[key]
-		if !ok {
-			return false
-		}
-
-		for innerKey, oursInner := range ours {
-			theirsInner, ok := theirs[innerKey]
-			if !ok {
-				return false
-			}
-
-			if !oursInner.equals(theirsInner) {
-				return false
-			}
-		}
+	if values.Kinds == nil {
+		return stringSet(values.Values)
 	}
 
-	for key, theirs := range theirsNormalized {
-		ours, ok := oursNormalized[key]
-		if !ok {
-			return false
-		}
-
-		for innerKey, theirsInner := range theirs {
-			oursInner, ok := ours[innerKey]
-			if !ok {
-				return false
-			}
-
-			if !theirsInner.equals(oursInner) {
-				return false
-			}
+	set := make(stringSet, len(values.Values))
+	for i, v := range values.Values {
+		switch values.kind(i) {
+		case numberValue:
+			v = canonicalNumber(v)
+		case structuredValue:
+			v = canonicalStructured(v)
 		}
+		set[i] = v
 	}
-
-	return true
+	return set
 }
 
-type stringSet []string
-type principalStringSet stringSet
-
-// newStringSet constructs an stringSet from an interface{} - which
-// may be nil, a single string, or []interface{} (each of which is a string).
-// This corresponds with how structures come off the JSON unmarshaler
-// without any custom encoding rules.
-func newStringSet(members interface{}) stringSet {
-	if members == nil {
-		return stringSet{}
+// texts returns the values with numbers in canonical form and structured
+// values as their source text.
+func (values valueList) texts() []string {
+	if values.Kinds == nil {
+		return values.Values
 	}
 
-	switch v := members.(type) {
-	case string:
-		return stringSet{v}
-	case bool:
-		return stringSet{strconv.FormatBool(v)}
-	case float64:
-		return stringSet{strconv.FormatFloat(v, 'f', -1, 64)}
-	case []interface{}:
-		var actions []string
-		for _, action := range v {
-			switch action := action.(type) {
-			case string:
-				actions = append(actions, action)
-			case bool:
-				actions = append(actions, strconv.FormatBool(action))
-			case float64:
-				actions = append(actions, strconv.FormatFloat(action, 'f', -1, 64))
-			default:
-				return nil
-			}
+	texts := make([]string, len(values.Values))
+	for i, v := range values.Values {
+		if values.kind(i) == numberValue {
+			v = canonicalNumber(v)
 		}
-		if len(actions) == 0 {
-			return stringSet{}
-		}
-		return stringSet(actions)
-	default:
-		return nil
+		texts[i] = v
 	}
+	return texts
 }
 
-func newPrincipalStringSet(members interface{}) principalStringSet {
-	return principalStringSet(newStringSet(members))
-}
-
-func (ours stringSet) equals(theirs stringSet) bool {
-	if ours == nil || theirs == nil {
-		return false
-	}
-
-	if len(ours) != len(theirs) {
-		return false
+// canonicalNumber returns a canonical form of a JSON number literal that
+// is equal for two literals exactly when they have the same decimal
+// value, so 1.50, 15e-1 and 1.5 are all written as 1.5. Unlike converting
+// through float64, no precision is lost for long integers.
+func canonicalNumber(text string) string {
+	negative := strings.HasPrefix(text, "-")
+	mantissa, expText, _ := strings.Cut(strings.ToLower(strings.TrimPrefix(text, "-")), "e")
+
+	exp := 0
+	if expText != "" {
+		var err error
+		if exp, err = strconv.Atoi(expText); err != nil {
+			return text
+		}
 	}
 
-	ourMap := map[string]struct{}{}
-	theirMap := map[string]struct{}{}
-
-	for _, str := range ours {
-		ourMap[str] = struct{}{}
+	// The value is digits × 10^exp.
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	exp -= len(fracPart)
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+	digits = trimmed
+
+	var s string
+	switch {
+	case exp >= 0 && exp <= maxPlainExponent:
+		s = digits + strings.Repeat("0", exp)
+	case exp < 0 && -exp < len(digits):
+		s = digits[:len(digits)+exp] + "." + digits[len(digits)+exp:]
+	case exp < 0 && -exp-len(digits) <= maxPlainExponent:
+		s = "0." + strings.Repeat("0", -exp-len(digits)) + digits
+	default:
+		s = digits + "e" + strconv.Itoa(exp)
 	}
 
-	for _, str := range theirs {
-		theirMap[str] = struct{}{}
+	if negative {
+		return "-" + s
 	}
-
-	return reflect.DeepEqual(ourMap, theirMap)
+	return s
 }
 
-func (ours principalStringSet) equals(theirs principalStringSet) bool {
-	if len(ours) != len(theirs) {
-		return false
-	}
+// isNumericOperator reports whether a condition operator, such as
+// NumericLessThan or ForAnyValue:NumericEqualsIfExists, compares numbers.
+func isNumericOperator(operator string) bool {
+	return strings.HasPrefix(operator[strings.LastIndex(operator, ":")+1:], "Numeric")
+}
 
-	for _, ourPrincipal := range ours {
-		matches := false
-		for _, theirPrincipal := range theirs {
-			if stringPrincipalsEqual(ourPrincipal, theirPrincipal) {
-				matches = true
-				break
-			}
-		}
-		if !matches {
-			return false
+// numericSet returns set with the strings that are number literals written
+// in canonical form, as AWS services often return numbers as strings.
+func numericSet(set stringSet) stringSet {
+	numeric := make(stringSet, len(set))
+	for i, v := range set {
+		if numberRegex.MatchString(v) {
+			v = canonicalNumber(v)
 		}
+		numeric[i] = v
 	}
-
-	return true
+	return numeric
 }
 
-func stringSlicesEqualIgnoreOrder(s1, s2 []string) bool {
-	if len(s1) != len(s2) {
-		return false
-	}
+// maxPlainExponent bounds the number of zeros canonicalNumber writes out
+// before switching to exponent notation.
+const maxPlainExponent = 64
 
-	sort.Strings(s1)
-	sort.Strings(s2)
+// stringSlicesEqualIgnoreOrder reports whether s1 and s2 hold the same
+// strings, ignoring order and duplicates.
+func stringSlicesEqualIgnoreOrder(s1, s2 []string) bool {
+	return reflect.DeepEqual(newMemberSet(s1), newMemberSet(s2))
+}
 
-	return reflect.DeepEqual(s1, s2)
+// newMemberSet returns the distinct members of a string slice.
+func newMemberSet(members []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+	return set
 }
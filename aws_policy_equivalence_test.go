@@ -6,6 +6,9 @@ package awspolicy
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -321,6 +324,234 @@ func TestPolicyEquivalence(t *testing.T) {
 			policy2:    policyTest44b,
 			equivalent: true,
 		},
+		{
+			name:       "Wildcard string principal versus AWS wildcard principal",
+			policy1:    policyTest45a,
+			policy2:    policyTest45b,
+			equivalent: true,
+		},
+		{
+			name:       "Wildcard string principal versus AWS account principal",
+			policy1:    policyTest45a,
+			policy2:    policyTest45c,
+			equivalent: false,
+		},
+		{
+			name:       "Regional service principal",
+			policy1:    policyTest46a,
+			policy2:    policyTest46b,
+			equivalent: false,
+		},
+		{
+			name:       "China partition service principal",
+			policy1:    policyTest47a,
+			policy2:    policyTest47b,
+			equivalent: true,
+		},
+		{
+			name:       "Different regional service principal",
+			policy1:    policyTest46a,
+			policy2:    policyTest47c,
+			equivalent: false,
+		},
+		{
+			name:       "Service principals in different regions",
+			policy1:    policyTest47d,
+			policy2:    policyTest47e,
+			equivalent: false,
+		},
+		{
+			name:       "Regional and global service principals",
+			policy1:    policyTest47d,
+			policy2:    policyTest47f,
+			equivalent: false,
+		},
+		{
+			name:       "Service principals in different regions beside a global one",
+			policy1:    policyTest47i,
+			policy2:    policyTest47j,
+			equivalent: false,
+		},
+		{
+			name:       "Service principal outside the China partition domain",
+			policy1:    policyTest47g,
+			policy2:    policyTest47h,
+			equivalent: false,
+		},
+		{
+			name:       "Different string principals",
+			policy1:    policyTest48a,
+			policy2:    policyTest48b,
+			equivalent: false,
+		},
+		{
+			name:       "Empty Condition block and missing Condition",
+			policy1:    policyTest49a,
+			policy2:    policyTest49b,
+			equivalent: true,
+		},
+		{
+			name:       "Empty condition operator and missing Condition",
+			policy1:    policyTest49a,
+			policy2:    policyTest49c,
+			equivalent: true,
+		},
+		{
+			name:       "Empty condition values and missing condition key",
+			policy1:    policyTest50a,
+			policy2:    policyTest50b,
+			equivalent: true,
+		},
+		{
+			name:       "Empty condition values and non-empty condition values",
+			policy1:    policyTest49a,
+			policy2:    policyTest50b,
+			equivalent: false,
+		},
+		{
+			name:       "Empty Action and Resource and missing Action and Resource",
+			policy1:    policyTest51a,
+			policy2:    policyTest51b,
+			equivalent: true,
+		},
+		{
+			name:       "Duplicate Action, Resource and condition values",
+			policy1:    policyTest52a,
+			policy2:    policyTest52b,
+			equivalent: true,
+		},
+		{
+			name:       "Duplicate principals are not matched asymmetrically",
+			policy1:    policyTest53a,
+			policy2:    policyTest53b,
+			equivalent: false,
+		},
+		{
+			name:       "Account ID and root IAM user ARN in the same list",
+			policy1:    policyTest54a,
+			policy2:    policyTest54b,
+			equivalent: true,
+		},
+		{
+			name:       "Repeated statements are matched one to one",
+			policy1:    policyTest55a,
+			policy2:    policyTest55b,
+			equivalent: false,
+		},
+		{
+			name:       "Root IAM user ARNs in different partitions",
+			policy1:    policyTest56a,
+			policy2:    policyTest56b,
+			equivalent: false,
+		},
+		{
+			name:       "Account ID matches a root IAM user ARN in any partition",
+			policy1:    policyTest56c,
+			policy2:    policyTest56b,
+			equivalent: true,
+		},
+		{
+			name:       "Repeated statements pair up root IAM user ARNs by partition",
+			policy1:    policyTest56d,
+			policy2:    policyTest56e,
+			equivalent: true,
+		},
+		{
+			name:       "Repeated statements with root IAM user ARNs in other partitions",
+			policy1:    policyTest56d,
+			policy2:    policyTest56f,
+			equivalent: false,
+		},
+		{
+			name:       "Large integer condition values differing in the last digit",
+			policy1:    policyTest57a,
+			policy2:    policyTest57b,
+			equivalent: false,
+		},
+		{
+			name:       "Large integer condition values with and without quotes",
+			policy1:    policyTest57a,
+			policy2:    policyTest57c,
+			equivalent: true,
+		},
+		{
+			name:       "Quoted numeric condition value with trailing zeros",
+			policy1:    policyTest57d,
+			policy2:    policyTest57e,
+			equivalent: true,
+		},
+		{
+			name:       "Quoted numeric condition values with the same decimal value",
+			policy1:    policyTest57d,
+			policy2:    policyTest57f,
+			equivalent: true,
+		},
+		{
+			name:       "Quoted number under a string operator",
+			policy1:    policyTest57g,
+			policy2:    policyTest57h,
+			equivalent: false,
+		},
+		{
+			name:       "Numeric condition values with the same decimal value",
+			policy1:    policyTest58a,
+			policy2:    policyTest58b,
+			equivalent: true,
+		},
+		{
+			name:       "Structured condition value is equivalent to itself",
+			policy1:    policyTest59a,
+			policy2:    policyTest59a,
+			equivalent: true,
+		},
+		{
+			name:       "Structured condition values with reordered keys and elements",
+			policy1:    policyTest59a,
+			policy2:    policyTest59b,
+			equivalent: true,
+		},
+		{
+			name:       "Different structured condition values",
+			policy1:    policyTest59a,
+			policy2:    policyTest59c,
+			equivalent: false,
+		},
+		{
+			name:       "Structured condition value versus string",
+			policy1:    policyTest60a,
+			policy2:    policyTest60b,
+			equivalent: false,
+		},
+		{
+			name:       "Trust policy with account ID principal and as returned by IAM",
+			policy1:    policyTest61a,
+			policy2:    policyTest61b,
+			equivalent: true,
+		},
+		{
+			name:       "Bucket policy and as returned by S3",
+			policy1:    policyTest62a,
+			policy2:    policyTest62b,
+			equivalent: true,
+		},
+		{
+			name:       "Queue policy and as returned by SQS",
+			policy1:    policyTest63a,
+			policy2:    policyTest63b,
+			equivalent: true,
+		},
+		{
+			name:       "Identity policy and as returned by IAM",
+			policy1:    policyTest64a,
+			policy2:    policyTest64b,
+			equivalent: true,
+		},
+		{
+			name:       "Key policy and as returned by KMS",
+			policy1:    policyTest65a,
+			policy2:    policyTest65b,
+			equivalent: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -1610,6 +1841,192 @@ const policyTest43b = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow"
 const policyTest44a = ``
 const policyTest44b = `{}`
 
+const policyTest45a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`
+const policyTest45b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`
+const policyTest45c = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"123456789012"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`
+
+const policyTest46a = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":["logs.amazonaws.com","ec2.amazonaws.com"]}}],"Version":"2012-10-17"}`
+const policyTest46b = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":["ec2.amazonaws.com","logs.us-gov-west-1.amazonaws.com"]}}],"Version":"2012-10-17"}`
+
+const policyTest47a = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"}}],"Version":"2012-10-17"}`
+const policyTest47b = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com.cn"}}],"Version":"2012-10-17"}`
+const policyTest47c = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":["lambda.us-east-1.amazonaws.com","ec2.amazonaws.com"]}}],"Version":"2012-10-17"}`
+const policyTest47d = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.us-east-1.amazonaws.com"}}],"Version":"2012-10-17"}`
+const policyTest47e = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.eu-west-1.amazonaws.com"}}],"Version":"2012-10-17"}`
+const policyTest47f = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.amazonaws.com"}}],"Version":"2012-10-17"}`
+const policyTest47g = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"example.com"}}],"Version":"2012-10-17"}`
+const policyTest47h = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"example.com.cn"}}],"Version":"2012-10-17"}`
+const policyTest47i = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.us-east-1.amazonaws.com"}},{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.amazonaws.com"}}],"Version":"2012-10-17"}`
+const policyTest47j = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.eu-west-1.amazonaws.com"}},{"Action":"sts:AssumeRole","Effect":"Allow","Principal":{"Service":"logs.amazonaws.com"}}],"Version":"2012-10-17"}`
+
+const policyTest48a = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":"arn:aws:iam::123456789012:root"}],"Version":"2012-10-17"}`
+const policyTest48b = `{"Statement":[{"Action":"sts:AssumeRole","Effect":"Allow","Principal":"arn:aws:iam::210987654321:root"}],"Version":"2012-10-17"}`
+
+const policyTest49a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+const policyTest49b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{}}]}`
+const policyTest49c = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{}}}]}`
+
+const policyTest50a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"aws:PrincipalTag/team":"a","aws:PrincipalTag/env":[]},"Bool":{"aws:SecureTransport":[]}}}]}`
+const policyTest50b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"aws:PrincipalTag/team":["a"]}}}]}`
+
+const policyTest51a = `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","NotAction":"s3:*","Action":[],"NotResource":"arn:aws:s3:::bucket","Resource":[]}]}`
+const policyTest51b = `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","NotAction":"s3:*","NotResource":"arn:aws:s3:::bucket"}]}`
+
+const policyTest52a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:GetObject"],"NotResource":["arn:aws:s3:::a","arn:aws:s3:::b","arn:aws:s3:::a"],"Condition":{"StringEquals":{"aws:PrincipalTag/team":["a","a"]}}}]}`
+const policyTest52b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","NotResource":["arn:aws:s3:::b","arn:aws:s3:::a"],"Condition":{"StringEquals":{"aws:PrincipalTag/team":"a"}}}]}`
+
+const policyTest53a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":["arn:aws:iam::123456789012:role/a","arn:aws:iam::123456789012:role/a"]}}]}`
+const policyTest53b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":["arn:aws:iam::123456789012:role/a","arn:aws:iam::123456789012:role/b"]}}]}`
+
+const policyTest54a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":["123456789012","arn:aws:iam::123456789012:root"]}}]}`
+const policyTest54b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"123456789012"}}]}`
+
+const policyTest55a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"},{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"},{"Effect":"Deny","Action":"s3:PutObject","Resource":"*"}]}`
+const policyTest55b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"},{"Effect":"Deny","Action":"s3:PutObject","Resource":"*"},{"Effect":"Deny","Action":"s3:PutObject","Resource":"*"}]}`
+
+const policyTest56a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws:iam::123456789012:root"}}]}`
+const policyTest56b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws-cn:iam::123456789012:root"}}]}`
+const policyTest56c = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"123456789012"}}]}`
+const policyTest56d = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"123456789012"}},{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws:iam::123456789012:root"}}]}`
+const policyTest56e = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws:iam::123456789012:root"}},{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws-cn:iam::123456789012:root"}}]}`
+const policyTest56f = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws-cn:iam::123456789012:root"}},{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws-us-gov:iam::123456789012:root"}}]}`
+
+const policyTest57a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"NumericEquals":{"s3:object-id":12345678901234567890},"DateLessThan":{"aws:EpochTime":1700000000001}}}]}`
+const policyTest57b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"NumericEquals":{"s3:object-id":12345678901234567891},"DateLessThan":{"aws:EpochTime":1700000000001}}}]}`
+const policyTest57c = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"NumericEquals":{"s3:object-id":["12345678901234567890"]},"DateLessThan":{"aws:EpochTime":"1700000000001"}}}]}`
+
+const policyTest57d = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:ListBucket","Resource":"*","Condition":{"NumericLessThanEquals":{"s3:max-keys":"1.50"}}}]}`
+const policyTest57e = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:ListBucket","Resource":"*","Condition":{"NumericLessThanEquals":{"s3:max-keys":1.5}}}]}`
+const policyTest57f = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:ListBucket","Resource":"*","Condition":{"NumericLessThanEquals":{"s3:max-keys":"1.5"}}}]}`
+const policyTest57g = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:ListBucket","Resource":"*","Condition":{"StringEquals":{"s3:prefix":"1.50"}}}]}`
+const policyTest57h = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:ListBucket","Resource":"*","Condition":{"StringEquals":{"s3:prefix":1.5}}}]}`
+
+const policyTest58a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"NumericLessThan":{"s3:max-keys":[1.50, 1e3, -0]}}}]}`
+const policyTest58b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"NumericLessThan":{"s3:max-keys":["1000", 15E-1, 0]}}}]}`
+
+const policyTest59a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"custom:Settings":[{"tier":"gold","regions":["us-east-1","eu-west-1"],"limit":10},["a","b"],null]}}}]}`
+const policyTest59b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"custom:Settings":[null,["b","a"],{"limit":"10","regions":["eu-west-1","us-east-1"],"tier":"gold"}]}}}]}`
+const policyTest59c = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"custom:Settings":[{"tier":"silver","regions":["us-east-1","eu-west-1"],"limit":10},["a","b"],null]}}}]}`
+
+const policyTest60a = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"custom:Settings":{"tier":"gold"}}}}]}`
+const policyTest60b = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"custom:Settings":"{\"tier\":\"gold\"}"}}}]}`
+
+const policyTest61a = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"AWS": ["123456789012", "arn:aws:iam::210987654321:role/deploy"], "Service": "ec2.amazonaws.com"},
+      "Action": ["sts:AssumeRole"]
+    }
+  ]
+}`
+const policyTest61b = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:root", "arn:aws:iam::210987654321:role/deploy"], "Service": "ec2.amazonaws.com"},
+      "Action": ["sts:AssumeRole"]
+    }
+  ]
+}`
+
+const policyTest62a = `{
+  "Statement": {
+    "Resource": ["arn:aws-cn:s3:::bucket/*"],
+    "Action": ["s3:GetObject", "s3:GetObjectVersion"],
+    "Effect": "Allow",
+    "Principal": {"AWS": ["123456789012"]},
+    "Sid": "Read",
+    "Condition": {}
+  },
+  "Version": "2012-10-17"
+}`
+const policyTest62b = `{"Version":"2012-10-17","Statement":[{"Sid":"Read","Effect":"Allow","Principal":{"AWS":"arn:aws-cn:iam::123456789012:root"},"Action":["s3:GetObject","s3:GetObjectVersion"],"Resource":"arn:aws-cn:s3:::bucket/*"}]}`
+
+const policyTest63a = `{
+  "Version": "2012-10-17",
+  "Id": "queue",
+  "Statement": [
+    {
+      "Effect": "Deny",
+      "NotPrincipal": {"AWS": ["123456789012", "210987654321"]},
+      "Action": ["sqs:*"],
+      "Resource": ["arn:aws:sqs:us-east-1:123456789012:queue"],
+      "Condition": {"Bool": {"aws:SecureTransport": ["false"]}, "StringEquals": {}}
+    }
+  ]
+}`
+const policyTest63b = `{"Version":"2012-10-17","Id":"queue","Statement":[{"Effect":"Deny","NotPrincipal":{"AWS":["arn:aws:iam::123456789012:root","arn:aws:iam::210987654321:root"]},"Action":"sqs:*","Resource":"arn:aws:sqs:us-east-1:123456789012:queue","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`
+
+const policyTest64a = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Action": ["s3:GetObject"],
+    "Resource": "*",
+    "Condition": {}
+  }
+}`
+const policyTest64b = `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":["s3:GetObject"],"Resource":"*","Condition":{}}}`
+
+const policyTest65a = `{
+  "Version": "2012-10-17",
+  "Id": "key-default-1",
+  "Statement": [
+    {
+      "Sid": "Enable IAM User Permissions",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["111122223333"]},
+      "Action": "kms:*",
+      "Resource": "*"
+    }
+  ]
+}`
+const policyTest65b = `{
+  "Version": "2012-10-17",
+  "Id": "key-default-1",
+  "Statement": [
+    {
+      "Sid": "Enable IAM User Permissions",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::111122223333:root"]},
+      "Action": "kms:*",
+      "Resource": "*"
+    }
+  ]
+}`
+
+func TestCanonicalNumber(t *testing.T) {
+	cases := []struct {
+		number   string
+		expected string
+	}{
+		{"0", "0"},
+		{"-0", "0"},
+		{"0.000", "0"},
+		{"42", "42"},
+		{"-42", "-42"},
+		{"1.50", "1.5"},
+		{"15e-1", "1.5"},
+		{"0.015", "0.015"},
+		{"1.5E-2", "0.015"},
+		{"1e3", "1000"},
+		{"1000.0", "1000"},
+		{"12345678901234567890", "12345678901234567890"},
+		{"12345678901234567891", "12345678901234567891"},
+		{"1e100", "1e100"},
+		{"1.0e-100", "1e-100"},
+	}
+
+	for _, tc := range cases {
+		if got := canonicalNumber(tc.number); got != tc.expected {
+			t.Errorf("canonicalNumber(%q)\n  Expected: %s\n       Got: %s", tc.number, tc.expected, got)
+		}
+	}
+}
+
 func TestStringValueSlicesEqualIgnoreOrder(t *testing.T) {
 	equal := []interface{}{
 		[]interface{}{
@@ -1628,6 +2045,10 @@ func TestStringValueSlicesEqualIgnoreOrder(t *testing.T) {
 			[]string{"Application", "Barrier", "Chilly", "Donut"},
 			[]string{"Barrier", "Application", "Donut", "Chilly"},
 		},
+		[]interface{}{
+			[]string{"a", "b", "a"},
+			[]string{"b", "a"},
+		},
 	}
 	for _, v := range equal {
 		if !stringSlicesEqualIgnoreOrder(v.([]interface{})[0].([]string), v.([]interface{})[1].([]string)) {
@@ -1664,6 +2085,67 @@ func TestStringValueSlicesEqualIgnoreOrder(t *testing.T) {
 	}
 }
 
+func TestStringValueSlicesEqualIgnoreOrderDoesNotModifyArguments(t *testing.T) {
+	s1 := []string{"c", "a", "b"}
+	s2 := []string{"b", "c", "a"}
+
+	if !stringSlicesEqualIgnoreOrder(s1, s2) {
+		t.Fatalf("%v should be equal: %v", s1, s2)
+	}
+
+	if !reflect.DeepEqual(s1, []string{"c", "a", "b"}) || !reflect.DeepEqual(s2, []string{"b", "c", "a"}) {
+		t.Fatalf("Arguments were modified: %v, %v", s1, s2)
+	}
+}
+
+// TestPolicyEquivalenceConcurrent is intended to be run with the race
+// detector enabled (go test -race).
+func TestPolicyEquivalenceConcurrent(t *testing.T) {
+	const goroutines = 16
+	const iterations = 50
+
+	cases := []struct {
+		policy1    string
+		policy2    string
+		equivalent bool
+	}{
+		{policyTest2a, policyTest2b, true},
+		{policyTest3a, policyTest3b, false},
+		{policyTest17a, policyTest17b, true},
+		{policyTest29a, policyTest29b, true},
+		{policyTest37a, policyTest37b, true},
+		{policyTest46a, policyTest46b, false},
+		{policyTest53a, policyTest53b, false},
+	}
+
+	opts := []Option{
+		WithAccountIDMapping(map[string]string{"123456789012": "210987654321"}),
+		WithPartitionMapping(map[string]string{"aws-us-gov": "aws"}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				for _, tc := range cases {
+					equal, err := PoliciesAreEquivalent(tc.policy1, tc.policy2, opts...)
+					if err != nil {
+						t.Errorf("Unexpected error: %s", err)
+						return
+					}
+					if equal != tc.equivalent {
+						t.Errorf("Bad:\n  Expected: %t\n       Got: %t\n", tc.equivalent, equal)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestIntermediatePolicyDocument(t *testing.T) {
 	cases := []struct {
 		name                   string
@@ -1699,10 +2181,12 @@ func TestIntermediatePolicyDocument(t *testing.T) {
 					{
 						Sid:    "",
 						Effect: "Allow",
-						Principals: map[string]interface{}{
-							"Service": "spotfleet.amazonaws.com",
+						Principals: &principalsBlock{
+							Types: map[string]valueList{
+								"Service": {Values: []string{"spotfleet.amazonaws.com"}, Single: true},
+							},
 						},
-						Actions: "sts:AssumeRole",
+						Actions: valueList{Values: []string{"sts:AssumeRole"}, Single: true},
 					},
 				},
 			},
@@ -1711,13 +2195,7 @@ func TestIntermediatePolicyDocument(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			policy1intermediate := &intermediatePolicyDocument{}
-			err := json.Unmarshal([]byte(tc.inputPolicy), policy1intermediate)
-			if err != nil {
-				t.Fatalf("Error unmarshaling policy: %s", err)
-			}
-
-			actual, err := policy1intermediate.document()
+			actual, err := parsePolicy(tc.inputPolicy)
 
 			if !tc.err && err != nil {
 				t.Fatalf("Unexpected error: %s", err)
@@ -1732,3 +2210,78 @@ func TestIntermediatePolicyDocument(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkPoliciesAreEquivalent(b *testing.B) {
+	for _, n := range []int{10, 100, 500, 1000} {
+		policy1 := generateLargePolicy(b, n, false)
+		policy2 := generateLargePolicy(b, n, true)
+
+		b.Run(fmt.Sprintf("statements=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				equal, err := PoliciesAreEquivalent(policy1, policy2)
+				if err != nil {
+					b.Fatalf("Unexpected error: %s", err)
+				}
+				if !equal {
+					b.Fatal("Expected policies to be equivalent")
+				}
+			}
+		})
+	}
+}
+
+// generateLargePolicy returns a policy with n distinct statements. When
+// reversed is true the statements, and the arrays inside them, are written
+// in reverse order and single element arrays are written as strings, so
+// that the result is equivalent but not identical.
+func generateLargePolicy(tb testing.TB, n int, reversed bool) string {
+	tb.Helper()
+
+	list := func(values ...string) interface{} {
+		if reversed {
+			if len(values) == 1 {
+				return values[0]
+			}
+			r := make([]string, len(values))
+			for i, v := range values {
+				r[len(values)-1-i] = v
+			}
+			values = r
+		}
+		return values
+	}
+
+	statements := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		statements[i] = map[string]interface{}{
+			"Sid":    fmt.Sprintf("Statement%d", i),
+			"Effect": "Allow",
+			"Principal": map[string]interface{}{
+				"AWS": list("123456789012", fmt.Sprintf("arn:aws:iam::123456789012:role/role-%d", i)),
+			},
+			"Action":   list("s3:GetObject", "s3:PutObject", fmt.Sprintf("s3:Tag%d", i)),
+			"Resource": list(fmt.Sprintf("arn:aws:s3:::bucket-%d/*", i)),
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"aws:PrincipalTag/team": list("a", "b", fmt.Sprintf("team-%d", i)),
+				},
+			},
+		}
+	}
+	if reversed {
+		for i, j := 0, len(statements)-1; i < j; i, j = i+1, j-1 {
+			statements[i], statements[j] = statements[j], statements[i]
+		}
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		tb.Fatalf("Error marshaling policy: %s", err)
+	}
+
+	return string(policy)
+}
@@ -0,0 +1,218 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PolicyBuilder builds a policy document statement by statement:
+//
+//	policy, err := NewPolicy().
+//		Version("2012-10-17").
+//		Allow().
+//		Actions("s3:GetObject").
+//		Resources("arn:aws:s3:::bucket/*").
+//		Condition("StringEquals", "aws:PrincipalAccount", "123456789012").
+//		Build()
+//
+// Allow and Deny start a new statement, and the statement methods that
+// follow them add to it. Element methods may be called more than once to
+// add further values.
+//
+// The first mistake, such as adding an element before any statement has
+// been started, is reported by Build or JSON. A PolicyBuilder must not be
+// used by multiple goroutines at once.
+type PolicyBuilder struct {
+	doc       policyDocument
+	statement *policyStatement
+	err       error
+}
+
+// NewPolicy returns a builder for an empty policy document.
+func NewPolicy() *PolicyBuilder {
+	return &PolicyBuilder{}
+}
+
+// Version sets the policy's Version element.
+func (b *PolicyBuilder) Version(version string) *PolicyBuilder {
+	b.doc.Version = version
+	return b
+}
+
+// Id sets the policy's Id element.
+func (b *PolicyBuilder) Id(id string) *PolicyBuilder {
+	b.doc.Id = id
+	return b
+}
+
+// Allow starts a new statement with an Effect of Allow.
+func (b *PolicyBuilder) Allow() *PolicyBuilder {
+	return b.begin("Allow")
+}
+
+// Deny starts a new statement with an Effect of Deny.
+func (b *PolicyBuilder) Deny() *PolicyBuilder {
+	return b.begin("Deny")
+}
+
+func (b *PolicyBuilder) begin(effect string) *PolicyBuilder {
+	b.statement = &policyStatement{Effect: effect}
+	b.doc.Statements = append(b.doc.Statements, b.statement)
+	return b
+}
+
+// Sid sets the current statement's Sid element.
+func (b *PolicyBuilder) Sid(sid string) *PolicyBuilder {
+	if s := b.current("Sid"); s != nil {
+		s.Sid = sid
+	}
+	return b
+}
+
+// Actions adds actions to the current statement's Action element.
+func (b *PolicyBuilder) Actions(actions ...string) *PolicyBuilder {
+	if s := b.current("Actions"); s != nil {
+		appendValues(&s.Actions, actions)
+	}
+	return b
+}
+
+// NotActions adds actions to the current statement's NotAction element.
+func (b *PolicyBuilder) NotActions(actions ...string) *PolicyBuilder {
+	if s := b.current("NotActions"); s != nil {
+		appendValues(&s.NotActions, actions)
+	}
+	return b
+}
+
+// Resources adds resources to the current statement's Resource element.
+func (b *PolicyBuilder) Resources(resources ...string) *PolicyBuilder {
+	if s := b.current("Resources"); s != nil {
+		appendValues(&s.Resources, resources)
+	}
+	return b
+}
+
+// NotResources adds resources to the current statement's NotResource
+// element.
+func (b *PolicyBuilder) NotResources(resources ...string) *PolicyBuilder {
+	if s := b.current("NotResources"); s != nil {
+		appendValues(&s.NotResources, resources)
+	}
+	return b
+}
+
+// Principal adds principals of the given type (such as "AWS" or
+// "Service") to the current statement's Principal element. Use
+// Principal("AWS", "*") to allow every principal.
+func (b *PolicyBuilder) Principal(principalType string, principals ...string) *PolicyBuilder {
+	if s := b.current("Principal"); s != nil {
+		appendPrincipals(&s.Principals, principalType, principals)
+	}
+	return b
+}
+
+// NotPrincipal adds principals of the given type to the current
+// statement's NotPrincipal element.
+func (b *PolicyBuilder) NotPrincipal(principalType string, principals ...string) *PolicyBuilder {
+	if s := b.current("NotPrincipal"); s != nil {
+		appendPrincipals(&s.NotPrincipals, principalType, principals)
+	}
+	return b
+}
+
+// Condition adds values for a condition key under a condition operator,
+// such as Condition("StringEquals", "aws:SourceAccount", "123456789012"),
+// to the current statement's Condition element.
+func (b *PolicyBuilder) Condition(operator, key string, values ...string) *PolicyBuilder {
+	s := b.current("Condition")
+	if s == nil {
+		return b
+	}
+
+	if s.Conditions == nil {
+		s.Conditions = conditionsBlock{}
+	}
+	if s.Conditions[operator] == nil {
+		s.Conditions[operator] = map[string]valueList{}
+	}
+	list := s.Conditions[operator][key]
+	appendValues(&list, values)
+	s.Conditions[operator][key] = list
+	return b
+}
+
+// current returns the statement being built, or records an error and
+// returns nil if there is none.
+func (b *PolicyBuilder) current(method string) *policyStatement {
+	if b.statement == nil && b.err == nil {
+		b.err = fmt.Errorf("%s called before Allow or Deny", method)
+	}
+	return b.statement
+}
+
+func appendValues(list *valueList, values []string) {
+	if list.Values == nil {
+		list.Values = []string{}
+	}
+	list.Values = append(list.Values, values...)
+}
+
+func appendPrincipals(principals **principalsBlock, principalType string, values []string) {
+	if *principals == nil {
+		*principals = &principalsBlock{Types: map[string]valueList{}}
+	}
+	list := (*principals).Types[principalType]
+	appendValues(&list, values)
+	(*principals).Types[principalType] = list
+}
+
+// Build returns the policy. The policy is independent of the builder,
+// which may go on to add further statements.
+func (b *PolicyBuilder) Build() (*Policy, error) {
+	// Parsing the canonical JSON guarantees that the policy and its JSON
+	// are the same document.
+	policy, err := b.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(policy)
+}
+
+// JSON returns the policy as canonical JSON, as Policy.MarshalJSON writes
+// it.
+func (b *PolicyBuilder) JSON() (string, error) {
+	if err := b.validate(); err != nil {
+		return "", err
+	}
+
+	var e encoder
+	e.document(&b.doc)
+	return e.String(), nil
+}
+
+func (b *PolicyBuilder) validate() error {
+	if b.err != nil {
+		return fmt.Errorf("building policy: %w", b.err)
+	}
+
+	for i, s := range b.doc.Statements {
+		var err error
+		switch {
+		case s.Actions.Values != nil && s.NotActions.Values != nil:
+			err = errors.New("Action and NotAction can't both be set")
+		case s.Resources.Values != nil && s.NotResources.Values != nil:
+			err = errors.New("Resource and NotResource can't both be set")
+		case s.Principals != nil && s.NotPrincipals != nil:
+			err = errors.New("Principal and NotPrincipal can't both be set")
+		}
+		if err != nil {
+			return fmt.Errorf("building policy: Statement[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyBuilder(t *testing.T) {
+	builder := NewPolicy().
+		Version("2012-10-17").
+		Id("builder").
+		Allow().
+		Sid("Read").
+		Principal("AWS", "arn:aws:iam::123456789012:root").
+		Principal("Service", "ec2.amazonaws.com").
+		Actions("s3:GetObject", "s3:ListBucket").
+		Actions("s3:GetObject").
+		Resources("arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*").
+		Condition("StringEquals", "aws:SourceVpc", "vpc-1").
+		Condition("StringEquals", "aws:SourceVpc", "vpc-2").
+		Deny().
+		NotActions("s3:*").
+		Resources("*")
+
+	got, err := builder.JSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := `{"Version":"2012-10-17","Id":"builder","Statement":[{"Sid":"Read","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root","Service":"ec2.amazonaws.com"},"Action":["s3:GetObject","s3:ListBucket"],"Resource":["arn:aws:s3:::bucket","arn:aws:s3:::bucket/*"],"Condition":{"StringEquals":{"aws:SourceVpc":["vpc-1","vpc-2"]}}},{"Effect":"Deny","NotAction":"s3:*","Resource":"*"}]}`
+	if got != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+
+	equivalent, err := PoliciesAreEquivalent(got, `{
+  "Version": "2012-10-17",
+  "Id": "builder",
+  "Statement": [
+    {"Effect": "Deny", "NotAction": ["s3:*"], "Resource": ["*"]},
+    {
+      "Sid": "Read",
+      "Effect": "Allow",
+      "Principal": {"AWS": "123456789012", "Service": ["ec2.amazonaws.com"]},
+      "Action": ["s3:ListBucket", "s3:GetObject"],
+      "Resource": ["arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket"],
+      "Condition": {"StringEquals": {"aws:SourceVpc": ["vpc-2", "vpc-1"]}}
+    }
+  ]
+}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !equivalent {
+		t.Fatal("Expected built policy to be equivalent")
+	}
+
+	policy, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if marshaled, _ := policy.MarshalJSON(); string(marshaled) != got {
+		t.Fatalf("Bad Build\n  Expected: %s\n       Got: %s", got, marshaled)
+	}
+
+	// The built policy doesn't change with the builder.
+	builder.Allow().Actions("sqs:*").Resources("*")
+	if n := len(policy.Statements()); n != 2 {
+		t.Fatalf("Bad: expected 2 statements, got %d", n)
+	}
+}
+
+func TestPolicyBuilderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *PolicyBuilder
+		err     string
+	}{
+		{
+			name:    "Element before statement",
+			builder: NewPolicy().Actions("s3:*").Allow().Resources("*"),
+			err:     "building policy: Actions called before Allow or Deny",
+		},
+		{
+			name:    "Action and NotAction",
+			builder: NewPolicy().Allow().Resources("*").Deny().Actions("s3:*").NotActions("iam:*"),
+			err:     "building policy: Statement[1]: Action and NotAction can't both be set",
+		},
+		{
+			name:    "Principal and NotPrincipal",
+			builder: NewPolicy().Allow().Principal("AWS", "*").NotPrincipal("AWS", "123456789012"),
+			err:     "building policy: Statement[0]: Principal and NotPrincipal can't both be set",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.builder.Build(); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Bad error\n  Expected: %s\n       Got: %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// canonicalStatement is the normalized form of a policyStatement. Two
+// statements are equivalent exactly when their canonical forms are equal.
+//
+// Every element list is sorted and free of duplicates, empty principal
+// types and condition values are dropped, and principals are reduced to
+// the form AWS would compare them in.
+type canonicalStatement struct {
+	Sid           string
+	Effect        string
+	Actions       []string
+	NotActions    []string
+	Resources     []string
+	NotResources  []string
+	Principals    map[string][]string
+	NotPrincipals map[string][]string
+	Conditions    map[string]map[string][]string
+}
+
+// canonical returns the canonical form of statement.
+func (statement *policyStatement) canonical() *canonicalStatement {
+	if statement == nil {
+		return nil
+	}
+
+	c := &canonicalStatement{
+		Sid:           statement.Sid,
+		Effect:        strings.ToLower(statement.Effect),
+		Actions:       sortedSet(statement.Actions.set()),
+		NotActions:    sortedSet(statement.NotActions.set()),
+		Resources:     sortedSet(statement.Resources.set()),
+		NotResources:  sortedSet(statement.NotResources.set()),
+		Principals:    canonicalPrincipals(statement.Principals),
+		NotPrincipals: canonicalPrincipals(statement.NotPrincipals),
+	}
+
+	for operator, condition := range statement.Conditions.normalize() {
+		values := make(map[string][]string, len(condition))
+		for key, set := range condition {
+			if isNumericOperator(operator) {
+				set = numericSet(set)
+			}
+			values[key] = sortedSet(set)
+		}
+		if c.Conditions == nil {
+			c.Conditions = make(map[string]map[string][]string)
+		}
+		c.Conditions[operator] = values
+	}
+
+	return c
+}
+
+// canonicalKey returns a string that is equal for two statements exactly
+// when they are equivalent, so that statements can be matched by hashing.
+func (statement *policyStatement) canonicalKey() string {
+	return statement.canonical().key()
+}
+
+// key encodes the canonical statement unambiguously. Strings are length
+// prefixed and maps are written in key order.
+func (c *canonicalStatement) key() string {
+	if c == nil {
+		return "null"
+	}
+
+	var b keyBuilder
+	b.str(c.Sid)
+	b.str(c.Effect)
+	b.list(c.Actions)
+	b.list(c.NotActions)
+	b.list(c.Resources)
+	b.list(c.NotResources)
+	b.principals(c.Principals)
+	b.principals(c.NotPrincipals)
+
+	operators := slices.Sorted(maps.Keys(c.Conditions))
+	b.count(len(operators))
+	for _, operator := range operators {
+		b.str(operator)
+		condition := c.Conditions[operator]
+		keys := slices.Sorted(maps.Keys(condition))
+		b.count(len(keys))
+		for _, key := range keys {
+			b.str(key)
+			b.list(condition[key])
+		}
+	}
+
+	return b.String()
+}
+
+type keyBuilder struct {
+	strings.Builder
+}
+
+func (b *keyBuilder) count(n int) {
+	b.WriteString(strconv.Itoa(n))
+	b.WriteByte('#')
+}
+
+func (b *keyBuilder) str(s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
+
+func (b *keyBuilder) list(l []string) {
+	b.count(len(l))
+	for _, s := range l {
+		b.str(s)
+	}
+}
+
+func (b *keyBuilder) principals(p map[string][]string) {
+	types := slices.Sorted(maps.Keys(p))
+	b.count(len(types))
+	for _, t := range types {
+		b.str(t)
+		b.list(p[t])
+	}
+}
+
+// canonicalPrincipals normalizes a Principal or NotPrincipal element.
+// Missing, empty and all-empty elements all become nil.
+func canonicalPrincipals(principals *principalsBlock) map[string][]string {
+	if principals == nil {
+		return nil
+	}
+
+	var normalized map[string][]string
+	for principalType, val := range principals.Types {
+		set := val.set()
+		if len(set) == 0 {
+			continue
+		}
+		members := make([]string, len(set))
+		for i, principal := range set {
+			members[i] = canonicalPrincipal(principalType, principal)
+		}
+		if normalized == nil {
+			normalized = make(map[string][]string)
+		}
+		normalized[principalType] = sortedSet(members)
+	}
+	return normalized
+}
+
+// canonicalPrincipal returns the form of a single principal that is
+// compared. AWS converts an account ID principal to the root IAM user ARN
+// (ACCOUNTID == arn:PARTITION:iam::ACCOUNTID:root), so root ARNs are
+// reduced to their account ID; their partition is compared separately, by
+// rootPrincipals. Service principals are reduced as described by
+// canonicalServicePrincipal.
+func canonicalPrincipal(principalType, principal string) string {
+	if principalType == "Service" {
+		return canonicalServicePrincipal(principal)
+	}
+
+	if _, accountID, ok := rootUserARN(principal); ok {
+		return accountID
+	}
+
+	return principal
+}
+
+// rootUserARN returns the partition and account ID of a root IAM user ARN.
+func rootUserARN(principal string) (partition, accountID string, ok bool) {
+	parsed, err := arn.Parse(principal)
+	if err != nil || parsed.Service != "iam" || parsed.Resource != "root" || !accountIDRegex.MatchString(parsed.AccountID) {
+		return "", "", false
+	}
+	return parsed.Partition, parsed.AccountID, true
+}
+
+// samePrincipal reports whether two principals of the given type are
+// equivalent: an account ID matches its root user ARN in any partition,
+// but two root user ARNs match only within one partition.
+func samePrincipal(principalType, a, b string) bool {
+	if canonicalPrincipal(principalType, a) != canonicalPrincipal(principalType, b) {
+		return false
+	}
+	if principalType == "Service" {
+		return true
+	}
+	aPartition, _, aRoot := rootUserARN(a)
+	bPartition, _, bRoot := rootUserARN(b)
+	return !aRoot || !bRoot || aPartition == bPartition
+}
+
+// rootPrincipals records the partitions of the root user ARNs a statement
+// names, which its canonical key leaves out. It is nil for statements
+// that name no root user ARNs, which is the common case.
+type rootPrincipals map[rootKey]*accountRoots
+
+// rootKey identifies an account named in a Principal or NotPrincipal
+// element under a principal type.
+type rootKey struct {
+	element, principalType, accountID string
+}
+
+// accountRoots holds the partitions of the root user ARNs naming an
+// account, and whether the same element also names it by its ID.
+type accountRoots struct {
+	partitions map[string]struct{}
+	bare       bool
+}
+
+func (statement *policyStatement) rootPrincipals() rootPrincipals {
+	if statement == nil {
+		return nil
+	}
+
+	var roots rootPrincipals
+	roots = roots.add("Principal", statement.Principals)
+	roots = roots.add("NotPrincipal", statement.NotPrincipals)
+	return roots
+}
+
+func (roots rootPrincipals) add(element string, principals *principalsBlock) rootPrincipals {
+	if principals == nil {
+		return roots
+	}
+
+	for principalType, values := range principals.Types {
+		if principalType == "Service" {
+			continue
+		}
+		set := values.set()
+		for _, principal := range set {
+			partition, accountID, ok := rootUserARN(principal)
+			if !ok {
+				continue
+			}
+			if roots == nil {
+				roots = make(rootPrincipals)
+			}
+			key := rootKey{element, principalType, accountID}
+			if roots[key] == nil {
+				roots[key] = &accountRoots{partitions: make(map[string]struct{})}
+			}
+			roots[key].partitions[partition] = struct{}{}
+		}
+		for _, principal := range set {
+			if r := roots[rootKey{element, principalType, principal}]; r != nil {
+				r.bare = true
+			}
+		}
+	}
+	return roots
+}
+
+// match reports whether the root user ARNs of two statements with equal
+// canonical keys match. Every root user ARN in either statement must be
+// matched in the other by the account's ID or by a root user ARN in the
+// same partition.
+func (roots rootPrincipals) match(other rootPrincipals) bool {
+	return roots.matchedBy(other) && other.matchedBy(roots)
+}
+
+func (roots rootPrincipals) matchedBy(other rootPrincipals) bool {
+	for key, r := range roots {
+		// With equal keys, an account missing from other is named there
+		// by its ID only.
+		o := other[key]
+		if o == nil || o.bare {
+			continue
+		}
+		for partition := range r.partitions {
+			if _, ok := o.partitions[partition]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchRoots reports whether statements with equal canonical keys, given
+// by their root principals, can be paired up one to one so that the root
+// principals of each pair match.
+func matchRoots(ours, theirs []rootPrincipals) bool {
+	if len(ours) != len(theirs) {
+		return false
+	}
+	if !slices.ContainsFunc(ours, isRooted) && !slices.ContainsFunc(theirs, isRooted) {
+		return true
+	}
+
+	// A statement that can't be paired directly may take the partner of
+	// another statement that can be paired elsewhere (an augmenting path).
+	pairedWith := make([]int, len(theirs))
+	for j := range pairedWith {
+		pairedWith[j] = -1
+	}
+	var pair func(i int, seen []bool) bool
+	pair = func(i int, seen []bool) bool {
+		for j := range theirs {
+			if seen[j] || !ours[i].match(theirs[j]) {
+				continue
+			}
+			seen[j] = true
+			if pairedWith[j] < 0 || pair(pairedWith[j], seen) {
+				pairedWith[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range ours {
+		if !pair(i, make([]bool, len(theirs))) {
+			return false
+		}
+	}
+	return true
+}
+
+func isRooted(roots rootPrincipals) bool {
+	return roots != nil
+}
+
+// sortedSet returns the distinct members of set in sorted order. The
+// argument is not modified.
+func sortedSet(set []string) []string {
+	if len(set) == 0 {
+		return nil
+	}
+
+	sorted := make([]string, 0, len(set))
+	for member := range newMemberSet(set) {
+		sorted = append(sorted, member)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// canonicalStructured returns a canonical form of the source text of a
+// structured value. Object keys are sorted, array elements are sorted and
+// free of duplicates, and scalars are compared by their string form, as
+// they are at the top level. The form starts with a NUL byte so that it
+// can't be mistaken for a plain string value.
+func canonicalStructured(raw string) string {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return raw
+	}
+
+	var b strings.Builder
+	b.WriteByte(0)
+	writeCanonicalJSON(&b, v)
+	return b.String()
+}
+
+func writeCanonicalJSON(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case bool:
+		b.WriteString(strconv.Quote(strconv.FormatBool(v)))
+	case json.Number:
+		b.WriteString(strconv.Quote(canonicalNumber(v.String())))
+	case []interface{}:
+		elements := make([]string, len(v))
+		for i, element := range v {
+			var eb strings.Builder
+			writeCanonicalJSON(&eb, element)
+			elements[i] = eb.String()
+		}
+		b.WriteByte('[')
+		b.WriteString(strings.Join(sortedSet(elements), ","))
+		b.WriteByte(']')
+	case map[string]interface{}:
+		b.WriteByte('{')
+		for i, key := range slices.Sorted(maps.Keys(v)) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(key))
+			b.WriteByte(':')
+			writeCanonicalJSON(b, v[key])
+		}
+		b.WriteByte('}')
+	}
+}
@@ -0,0 +1,259 @@
+// Package cfnpolicy extracts the policy documents from CloudFormation
+// templates, including those synthesized by the AWS CDK, so that they can
+// be compared with another template or a deployed baseline by the logical
+// ID of the resource that holds them.
+package cfnpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/awspolicyequivalence/yamlpolicy"
+	"gopkg.in/yaml.v3"
+)
+
+// policyProperties are the resource properties that hold a policy
+// document. PolicyDocument covers IAM policies, the inline policies of
+// roles, users and groups, and bucket, queue and topic policies.
+var policyProperties = map[string]bool{
+	"PolicyDocument":           true,
+	"AssumeRolePolicyDocument": true,
+	"KeyPolicy":                true,
+}
+
+// Document is a policy document found in a template.
+type Document struct {
+	// LogicalID is the logical ID of the resource holding the document.
+	LogicalID string
+
+	// ResourceType is the type of the resource, such as AWS::IAM::Role.
+	ResourceType string
+
+	// Property is the path to the document within the resource's
+	// properties, such as KeyPolicy or Policies[0].PolicyDocument.
+	Property string
+
+	Policy *awspolicy.Policy
+
+	// Placeholders lists, in order of appearance, the intrinsic functions
+	// that could not be resolved and were written into the document as
+	// placeholders such as {{Ref:MyBucket}} or {{GetAtt:MyRole.Arn}}.
+	Placeholders []string
+}
+
+// Key identifies the document within a template: the logical ID and the
+// property path, such as MyRole.AssumeRolePolicyDocument.
+func (d *Document) Key() string {
+	return d.LogicalID + "." + d.Property
+}
+
+// Option configures how intrinsic functions are resolved.
+type Option func(*options)
+
+type options struct {
+	parameters map[string]string
+	resources  map[string]string
+	attributes map[string]string
+}
+
+// WithParameters returns an Option that gives the values of template
+// parameters, which otherwise take their Default, and of pseudo
+// parameters such as AWS::AccountId, AWS::Partition and AWS::Region.
+func WithParameters(values map[string]string) Option {
+	return func(o *options) {
+		o.parameters = merge(o.parameters, values)
+	}
+}
+
+// WithResources returns an Option that gives the value of a Ref to a
+// resource, usually its physical ID, by logical ID.
+func WithResources(values map[string]string) Option {
+	return func(o *options) {
+		o.resources = merge(o.resources, values)
+	}
+}
+
+// WithAttributes returns an Option that gives the value of a Fn::GetAtt
+// by logical ID and attribute name, written as in MyRole.Arn.
+func WithAttributes(values map[string]string) Option {
+	return func(o *options) {
+		o.attributes = merge(o.attributes, values)
+	}
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// Extract returns the policy documents held by the resources of a
+// CloudFormation template written in JSON or YAML, in order of logical ID
+// and, within a resource, in the order they are written. Documents are
+// found in every PolicyDocument, AssumeRolePolicyDocument and KeyPolicy
+// property, however deeply nested, and may be written as objects or as
+// JSON strings.
+//
+// Ref, Fn::GetAtt, Fn::Sub, Fn::Join, Fn::Select and Fn::Split, in both
+// their long and short YAML forms, are resolved using the values given by
+// opts and the Default of each parameter. Any other intrinsic function,
+// and any reference without a known value, is replaced with a
+// placeholder and listed in the document's Placeholders.
+//
+// Problems with a document are reported with the logical ID and property
+// of the document, wrapping a *awspolicy.ParseError that holds its
+// position in src.
+func Extract(src []byte, opts ...Option) ([]*Document, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var root yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(src)).Decode(&root); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("template is empty")
+		}
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	template := mappingValue(&root, "")
+	if template == nil {
+		return nil, errors.New("template is not an object")
+	}
+
+	parameters := make(map[string]string)
+	if params := mappingValue(template, "Parameters"); params != nil {
+		for i := 0; i+1 < len(params.Content); i += 2 {
+			if def := mappingValue(params.Content[i+1], "Default"); def != nil && def.Kind == yaml.ScalarNode {
+				parameters[params.Content[i].Value] = def.Value
+			}
+		}
+	}
+	for k, v := range o.parameters {
+		parameters[k] = v
+	}
+
+	e := &extractor{src: src, options: o, parameters: parameters}
+	if resources := mappingValue(template, "Resources"); resources != nil {
+		for i := 0; i+1 < len(resources.Content); i += 2 {
+			logicalID, resource := resources.Content[i].Value, resources.Content[i+1]
+
+			var resourceType string
+			if t := mappingValue(resource, "Type"); t != nil {
+				resourceType = t.Value
+			}
+			if properties := mappingValue(resource, "Properties"); properties != nil {
+				if err := e.walk(logicalID, resourceType, "", properties); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(e.documents, func(i, j int) bool {
+		return e.documents[i].LogicalID < e.documents[j].LogicalID
+	})
+	return e.documents, nil
+}
+
+type extractor struct {
+	src        []byte
+	options    *options
+	parameters map[string]string
+	documents  []*Document
+}
+
+// walk finds the policy documents in a resource's properties.
+func (e *extractor) walk(logicalID, resourceType, path string, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+
+			if !policyProperties[key] {
+				if err := e.walk(logicalID, resourceType, child, value); err != nil {
+					return err
+				}
+				continue
+			}
+
+			doc, err := e.document(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", logicalID, child, err)
+			}
+			doc.LogicalID, doc.ResourceType, doc.Property = logicalID, resourceType, child
+			e.documents = append(e.documents, doc)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			if err := e.walk(logicalID, resourceType, path+"["+strconv.Itoa(i)+"]", item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// document resolves the intrinsic functions in a policy document and
+// parses it.
+func (e *extractor) document(n *yaml.Node) (*Document, error) {
+	r := &resolver{options: e.options, parameters: e.parameters}
+	resolved := r.resolve(n)
+
+	var policy *awspolicy.Policy
+	var err error
+	switch {
+	case resolved.Kind == yaml.MappingNode:
+		policy, err = yamlpolicy.ParseNode(e.src, resolved)
+	case resolved.Kind == yaml.ScalarNode && r.isPlaceholder(resolved.Value):
+		err = fmt.Errorf("document at line %d is the unresolved intrinsic function %s", n.Line, resolved.Value)
+	case resolved.Kind == yaml.ScalarNode && resolved.ShortTag() == "!!str":
+		policy, err = awspolicy.ParsePolicy(resolved.Value)
+	default:
+		err = fmt.Errorf("not a policy document at line %d", n.Line)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Policy: policy, Placeholders: r.placeholders}, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or the mapping
+// itself if key is empty. It returns nil if there is no such value.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	if key == "" {
+		return n
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package cfnpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+const yamlTemplate = `AWSTemplateFormatVersion: "2010-09-09"
+Parameters:
+  BucketName:
+    Type: String
+    Default: example-bucket
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+    Properties:
+      Policies:
+        - PolicyName: read
+          PolicyDocument:
+            Version: "2012-10-17"
+            Statement:
+              - Effect: Allow
+                Action: s3:GetObject
+                Resource: !Sub arn:${AWS::Partition}:s3:::${BucketName}/${!aws:username}/*
+      AssumeRolePolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Effect: Allow
+            Principal:
+              Service: !Sub ec2.${AWS::URLSuffix}
+            Action: sts:AssumeRole
+  Key:
+    Type: AWS::KMS::Key
+    Properties:
+      KeyPolicy: |
+        {"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "kms:*", "Resource": "*"}}
+  QueuePolicy:
+    Type: AWS::SQS::QueuePolicy
+    Properties:
+      Queues:
+        - !Ref Queue
+      PolicyDocument:
+        Statement:
+          - Effect: Allow
+            Principal: "*"
+            Action: sqs:SendMessage
+            Resource: !GetAtt Queue.Arn
+            Condition:
+              ArnEquals:
+                aws:SourceArn: !Select [0, !Split [",", !Join [",", [!Ref Topic, other]]]]
+  Queue:
+    Type: AWS::SQS::Queue
+`
+
+// cdkTemplate is written the way the AWS CDK synthesizes templates.
+const cdkTemplate = `{
+  "Resources": {
+    "Role1ABCC5F0": {
+      "Type": "AWS::IAM::Role",
+      "Properties": {
+        "AssumeRolePolicyDocument": {
+          "Statement": [{"Action": "sts:AssumeRole", "Effect": "Allow", "Principal": {"Service": "ec2.amazonaws.com"}}],
+          "Version": "2012-10-17"
+        }
+      }
+    },
+    "RoleDefaultPolicy5FFB7DAB": {
+      "Type": "AWS::IAM::Policy",
+      "Properties": {
+        "PolicyDocument": {
+          "Statement": [{
+            "Action": "s3:GetObject",
+            "Effect": "Allow",
+            "Resource": {"Fn::Join": ["", ["arn:", {"Ref": "AWS::Partition"}, ":s3:::example-bucket/", {"Ref": "AWS::AccountId"}, "/*"]]}
+          }],
+          "Version": "2012-10-17"
+        },
+        "PolicyName": "RoleDefaultPolicy5FFB7DAB",
+        "Roles": [{"Ref": "Role1ABCC5F0"}]
+      }
+    },
+    "Topic": {"Type": "AWS::SNS::Topic"}
+  }
+}`
+
+func TestExtract(t *testing.T) {
+	docs, err := Extract([]byte(yamlTemplate), WithParameters(map[string]string{
+		"AWS::Partition": "aws",
+		"AWS::URLSuffix": "amazonaws.com",
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		key          string
+		resourceType string
+		policy       string
+		placeholders []string
+	}{
+		{
+			key:          "Key.KeyPolicy",
+			resourceType: "AWS::KMS::Key",
+			policy:       `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"kms:*","Resource":"*"}}`,
+		},
+		{
+			key:          "QueuePolicy.PolicyDocument",
+			resourceType: "AWS::SQS::QueuePolicy",
+			policy:       `{"Statement":{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"{{GetAtt:Queue.Arn}}","Condition":{"ArnEquals":{"aws:SourceArn":"{{Ref:Topic}}"}}}}`,
+			placeholders: []string{"{{GetAtt:Queue.Arn}}", "{{Ref:Topic}}"},
+		},
+		{
+			key:          "Role.Policies[0].PolicyDocument",
+			resourceType: "AWS::IAM::Role",
+			policy:       `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::example-bucket/${aws:username}/*"}}`,
+		},
+		{
+			key:          "Role.AssumeRolePolicyDocument",
+			resourceType: "AWS::IAM::Role",
+			policy:       `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}}`,
+		},
+	}
+
+	if len(docs) != len(cases) {
+		t.Fatalf("Bad: documents\n  Expected: %d\n       Got: %d", len(cases), len(docs))
+	}
+	for i, tc := range cases {
+		doc := docs[i]
+		if doc.Key() != tc.key || doc.ResourceType != tc.resourceType {
+			t.Fatalf("Bad: document %d\n  Expected: %s (%s)\n       Got: %s (%s)", i, tc.key, tc.resourceType, doc.Key(), doc.ResourceType)
+		}
+		want, err := awspolicy.ParsePolicy(tc.policy)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !doc.Policy.EquivalentTo(want) {
+			got, _ := doc.Policy.Marshal()
+			t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s", tc.key, tc.policy, got)
+		}
+		if !reflect.DeepEqual(doc.Placeholders, tc.placeholders) {
+			t.Fatalf("Bad: %s placeholders\n  Expected: %v\n       Got: %v", tc.key, tc.placeholders, doc.Placeholders)
+		}
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		error    string
+		line     int
+	}{
+		{
+			name:     "not a template",
+			template: `[]`,
+			error:    "template is not an object",
+		},
+		{
+			name: "bad statement",
+			template: `Resources:
+  Policy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      PolicyDocument:
+        Statement:
+          - Effect: [Allow]
+`,
+			error: "Policy: PolicyDocument: YAML: Statement[0].Effect:",
+			line:  7,
+		},
+		{
+			name: "unresolved document",
+			template: `Resources:
+  Policy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      PolicyDocument: !If [Prod, {}, {}]
+`,
+			error: "Policy: PolicyDocument: document at line 5 is the unresolved intrinsic function {{Fn::If}}",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := Extract([]byte(tc.template))
+		if err == nil || !strings.HasPrefix(err.Error(), tc.error) {
+			t.Fatalf("Bad: %s\n  Expected error: %s\n       Got: %v", tc.name, tc.error, err)
+		}
+
+		var parseErr *awspolicy.ParseError
+		if tc.line > 0 && (!errors.As(err, &parseErr) || parseErr.Line != tc.line) {
+			t.Fatalf("Bad: %s\n  Expected: error at line %d\n       Got: %v", tc.name, tc.line, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	current, err := Extract([]byte(cdkTemplate))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	resolved, err := Extract([]byte(cdkTemplate), WithParameters(map[string]string{
+		"AWS::Partition": "aws",
+		"AWS::AccountId": "123456789012",
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	baseline, err := Extract([]byte(`Resources:
+  Role1ABCC5F0:
+    Type: AWS::IAM::Role
+    Properties:
+      AssumeRolePolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          Effect: Allow
+          Action: [sts:AssumeRole]
+          Principal: {Service: [ec2.amazonaws.com]}
+  RoleDefaultPolicy5FFB7DAB:
+    Type: AWS::IAM::Policy
+    Properties:
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          Effect: Allow
+          Action: s3:GetObject
+          Resource: arn:aws:s3:::example-bucket/123456789012/*
+  Bucket:
+    Type: AWS::S3::BucketPolicy
+    Properties:
+      PolicyDocument:
+        Statement: []
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	type result struct {
+		key               string
+		current, baseline bool
+		equivalent        bool
+	}
+	summarize := func(comparisons []Comparison) []result {
+		var results []result
+		for _, c := range comparisons {
+			results = append(results, result{c.Key, c.Current != nil, c.Baseline != nil, c.Equivalent})
+		}
+		return results
+	}
+
+	got := summarize(Compare(current, baseline))
+	want := []result{
+		{"Bucket.PolicyDocument", false, true, false},
+		{"Role1ABCC5F0.AssumeRolePolicyDocument", true, true, true},
+		{"RoleDefaultPolicy5FFB7DAB.PolicyDocument", true, true, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bad: unresolved comparison\n  Expected: %v\n       Got: %v", want, got)
+	}
+
+	got = summarize(Compare(resolved, baseline))
+	want[2].equivalent = true
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bad: resolved comparison\n  Expected: %v\n       Got: %v", want, got)
+	}
+}
@@ -0,0 +1,59 @@
+package cfnpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"sort"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// Comparison is the result of comparing the documents with the same Key
+// in two templates.
+type Comparison struct {
+	Key string
+
+	// Current and Baseline are the documents being compared. One of them
+	// is nil if the document is only found in the other template.
+	Current  *Document
+	Baseline *Document
+
+	// Equivalent is true if both documents exist and are equivalent.
+	Equivalent bool
+}
+
+// Compare matches the documents extracted from two templates by Key and
+// compares each pair with Policy.EquivalentTo, passing opts. Comparisons
+// are returned in order of Key.
+//
+// Placeholders compare equal only to the same placeholder, so documents
+// that refer to resources should be extracted with WithResources and
+// WithAttributes when the baseline holds deployed values.
+func Compare(current, baseline []*Document, opts ...awspolicy.Option) []Comparison {
+	byKey := make(map[string]*Comparison)
+	for _, d := range current {
+		byKey[d.Key()] = &Comparison{Key: d.Key(), Current: d}
+	}
+	for _, d := range baseline {
+		c, ok := byKey[d.Key()]
+		if !ok {
+			c = &Comparison{Key: d.Key()}
+			byKey[d.Key()] = c
+		}
+		c.Baseline = d
+	}
+
+	comparisons := make([]Comparison, 0, len(byKey))
+	for _, c := range byKey {
+		if c.Current != nil && c.Baseline != nil {
+			c.Equivalent = c.Current.Policy.EquivalentTo(c.Baseline.Policy, opts...)
+		}
+		comparisons = append(comparisons, *c)
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		return comparisons[i].Key < comparisons[j].Key
+	})
+	return comparisons
+}
@@ -0,0 +1,16 @@
+module github.com/hashicorp/awspolicyequivalence/cfnpolicy
+
+go 1.23.0
+
+require (
+	github.com/hashicorp/awspolicyequivalence v1.8.0
+	github.com/hashicorp/awspolicyequivalence/yamlpolicy v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/aws/aws-sdk-go-v2 v1.36.1 // indirect
+
+// Ignored outside this repository. See "Nested modules" in README.md.
+replace github.com/hashicorp/awspolicyequivalence => ../
+
+replace github.com/hashicorp/awspolicyequivalence/yamlpolicy => ../yamlpolicy
@@ -0,0 +1,269 @@
+package cfnpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resolver replaces the intrinsic functions in a policy document with
+// their values, or with placeholders where they can't be resolved.
+type resolver struct {
+	options    *options
+	parameters map[string]string
+
+	placeholders []string
+}
+
+// resolve returns a copy of n with its intrinsic functions resolved.
+// Resolved values keep the position of the function they replace.
+func (r *resolver) resolve(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode {
+		return r.resolve(n.Alias)
+	}
+	if fn, arg, ok := intrinsic(n); ok {
+		return r.call(n, fn, arg)
+	}
+
+	resolved := *n
+	switch n.Kind {
+	case yaml.MappingNode:
+		resolved.Content = make([]*yaml.Node, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			resolved.Content[i] = n.Content[i]
+			resolved.Content[i+1] = r.resolve(n.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		resolved.Content = make([]*yaml.Node, len(n.Content))
+		for i, item := range n.Content {
+			resolved.Content[i] = r.resolve(item)
+		}
+	}
+	return &resolved
+}
+
+// intrinsic reports whether n is a call to an intrinsic function, either
+// in long form, as in {"Ref": "MyBucket"}, or in short form, as in
+// !Ref MyBucket, and returns the function's name and argument.
+func intrinsic(n *yaml.Node) (string, *yaml.Node, bool) {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		fn := n.Content[0].Value
+		if fn == "Ref" || strings.HasPrefix(fn, "Fn::") {
+			return fn, n.Content[1], true
+		}
+	}
+
+	if strings.HasPrefix(n.Tag, "!") && !strings.HasPrefix(n.Tag, "!!") {
+		fn := strings.TrimPrefix(n.Tag, "!")
+		if fn != "Ref" && fn != "Condition" {
+			fn = "Fn::" + fn
+		}
+
+		arg := *n
+		switch n.Kind {
+		case yaml.MappingNode:
+			arg.Tag = "!!map"
+		case yaml.SequenceNode:
+			arg.Tag = "!!seq"
+		default:
+			arg.Tag = "!!str"
+		}
+		return fn, &arg, true
+	}
+	return "", nil, false
+}
+
+func (r *resolver) call(n *yaml.Node, fn string, arg *yaml.Node) *yaml.Node {
+	switch fn {
+	case "Ref":
+		if arg.Kind == yaml.ScalarNode {
+			return r.str(n, r.ref(arg.Value))
+		}
+	case "Fn::GetAtt":
+		if name, ok := r.getAttName(arg); ok {
+			return r.str(n, r.getAtt(name))
+		}
+	case "Fn::Sub":
+		if s, ok := r.sub(arg); ok {
+			return r.str(n, s)
+		}
+	case "Fn::Join":
+		if s, ok := r.join(arg); ok {
+			return r.str(n, s)
+		}
+	case "Fn::Select":
+		if v, ok := r.selectElem(arg); ok {
+			return v
+		}
+	case "Fn::Split":
+		if v, ok := r.split(n, arg); ok {
+			return v
+		}
+	}
+	return r.str(n, r.placeholder(fn))
+}
+
+// str returns a string value at the position of n.
+func (r *resolver) str(n *yaml.Node, s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s, Line: n.Line, Column: n.Column}
+}
+
+// placeholder records and returns the placeholder for an unresolved
+// function or reference.
+func (r *resolver) placeholder(name string) string {
+	p := "{{" + name + "}}"
+	if !r.isPlaceholder(p) {
+		r.placeholders = append(r.placeholders, p)
+	}
+	return p
+}
+
+func (r *resolver) isPlaceholder(s string) bool {
+	for _, p := range r.placeholders {
+		if p == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *resolver) ref(name string) string {
+	if v, ok := r.parameters[name]; ok {
+		return v
+	}
+	if v, ok := r.options.resources[name]; ok {
+		return v
+	}
+	return r.placeholder("Ref:" + name)
+}
+
+func (r *resolver) getAtt(name string) string {
+	if v, ok := r.options.attributes[name]; ok {
+		return v
+	}
+	return r.placeholder("GetAtt:" + name)
+}
+
+// getAttName returns the argument of Fn::GetAtt written as
+// LogicalID.Attribute. The long form takes a list and the short form a
+// string.
+func (r *resolver) getAttName(arg *yaml.Node) (string, bool) {
+	switch arg.Kind {
+	case yaml.ScalarNode:
+		return arg.Value, strings.Contains(arg.Value, ".")
+	case yaml.SequenceNode:
+		if len(arg.Content) != 2 {
+			return "", false
+		}
+		attribute, ok := r.string(arg.Content[1])
+		return arg.Content[0].Value + "." + attribute, ok
+	}
+	return "", false
+}
+
+var subVariable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// sub evaluates Fn::Sub, which takes a string or a list of a string and
+// a map of variables.
+func (r *resolver) sub(arg *yaml.Node) (string, bool) {
+	template := arg
+	var variables *yaml.Node
+	if arg.Kind == yaml.SequenceNode {
+		if len(arg.Content) != 2 || arg.Content[1].Kind != yaml.MappingNode {
+			return "", false
+		}
+		template, variables = arg.Content[0], arg.Content[1]
+	}
+	if template.Kind != yaml.ScalarNode {
+		return "", false
+	}
+
+	return subVariable.ReplaceAllStringFunc(template.Value, func(match string) string {
+		name := match[2 : len(match)-1]
+		if strings.HasPrefix(name, "!") {
+			return "${" + name[1:] + "}"
+		}
+		if variables != nil {
+			if v := mappingValue(variables, name); v != nil {
+				if s, ok := r.string(v); ok {
+					return s
+				}
+				return r.placeholder("Sub:" + name)
+			}
+		}
+		if strings.Contains(name, ".") && !strings.HasPrefix(name, "AWS::") {
+			return r.getAtt(name)
+		}
+		return r.ref(name)
+	}), true
+}
+
+// join evaluates Fn::Join, which takes a delimiter and a list.
+func (r *resolver) join(arg *yaml.Node) (string, bool) {
+	if arg.Kind != yaml.SequenceNode || len(arg.Content) != 2 {
+		return "", false
+	}
+	list := r.resolve(arg.Content[1])
+	if list.Kind != yaml.SequenceNode {
+		return "", false
+	}
+
+	parts := make([]string, len(list.Content))
+	for i, item := range list.Content {
+		s, ok := r.string(item)
+		if !ok {
+			return "", false
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, arg.Content[0].Value), true
+}
+
+// selectElem evaluates Fn::Select, which takes an index and a list.
+func (r *resolver) selectElem(arg *yaml.Node) (*yaml.Node, bool) {
+	if arg.Kind != yaml.SequenceNode || len(arg.Content) != 2 {
+		return nil, false
+	}
+	index, ok := r.string(arg.Content[0])
+	if !ok {
+		return nil, false
+	}
+	i, err := strconv.Atoi(index)
+	list := r.resolve(arg.Content[1])
+	if err != nil || list.Kind != yaml.SequenceNode || i < 0 || i >= len(list.Content) {
+		return nil, false
+	}
+	return list.Content[i], true
+}
+
+// split evaluates Fn::Split, which takes a delimiter and a string.
+func (r *resolver) split(n, arg *yaml.Node) (*yaml.Node, bool) {
+	if arg.Kind != yaml.SequenceNode || len(arg.Content) != 2 {
+		return nil, false
+	}
+	s, ok := r.string(arg.Content[1])
+	if !ok {
+		return nil, false
+	}
+
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: n.Line, Column: n.Column}
+	for _, part := range strings.Split(s, arg.Content[0].Value) {
+		list.Content = append(list.Content, r.str(n, part))
+	}
+	return list, true
+}
+
+// string resolves n and returns its value if it is a scalar.
+func (r *resolver) string(n *yaml.Node) (string, bool) {
+	resolved := r.resolve(n)
+	if resolved.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return resolved.Value, true
+}
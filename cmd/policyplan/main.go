@@ -0,0 +1,85 @@
+// Command policyplan reports the policy documents changed by a Terraform
+// plan, leaving out changes that only reformat a policy:
+//
+//	terraform show -json tfplan | policyplan
+//
+// Every string attribute that holds a policy both before and after a
+// change is compared with PoliciesAreEquivalent. Real changes are listed
+// with their resource addresses, and equivalent ones are only counted
+// unless -all is given. With -detailed-exitcode the command exits with
+// status 2 if there are real changes.
+package main
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/awspolicyequivalence/tfplan"
+)
+
+func main() {
+	all := flag.Bool("all", false, "also list changes that only reformat a policy")
+	detailedExitCode := flag.Bool("detailed-exitcode", false, "exit with status 2 if a policy really changes")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [plan.json]\n\nReads the output of terraform show -json from the file or standard input.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		in = f
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	changes, err := tfplan.Analyze(in)
+	if err != nil {
+		fatal(err)
+	}
+
+	changed := report(os.Stdout, changes, *all)
+	if *detailedExitCode && changed > 0 {
+		os.Exit(2)
+	}
+}
+
+// report writes the changes to w and returns the number of real changes.
+func report(w io.Writer, changes []tfplan.Change, all bool) int {
+	changed := 0
+	for _, c := range changes {
+		if !c.Equivalent {
+			changed++
+		}
+	}
+
+	fmt.Fprintf(w, "%d policy changes: %d real, %d equivalent\n", len(changes), changed, len(changes)-changed)
+	for _, c := range changes {
+		switch {
+		case !c.Equivalent:
+			fmt.Fprintf(w, "  ~ %s\n", c)
+		case all:
+			fmt.Fprintf(w, "  = %s (formatting only)\n", c)
+		}
+	}
+	return changed
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "policyplan: %s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,170 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// conformanceDir holds the conformance corpus. Its format is described
+// in the README.md there.
+const conformanceDir = "testdata/conformance"
+
+// syntheticDir holds the cases within conformanceDir that were written by
+// hand rather than captured from AWS. They check the rules the package
+// documents, not what AWS does.
+var syntheticDir = filepath.Join(conformanceDir, "synthetic")
+
+// conformanceCase is a document submitted to AWS, the document AWS
+// returned for it, and whether the two must be equivalent.
+type conformanceCase struct {
+	Description string          `json:"description"`
+	Issue       string          `json:"issue"`
+	Submitted   json.RawMessage `json:"submitted"`
+	Returned    json.RawMessage `json:"returned"`
+	Equivalent  *bool           `json:"equivalent"`
+	Options     struct {
+		URLDecoding bool `json:"urlDecoding"`
+	} `json:"options"`
+}
+
+// readConformanceCase reads a case, checking that it has every required
+// field and no unknown ones.
+func readConformanceCase(path string) (*conformanceCase, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var c conformanceCase
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.Description == "":
+		return nil, errors.New("description is required")
+	case len(c.Submitted) == 0:
+		return nil, errors.New("submitted is required")
+	case len(c.Returned) == 0:
+		return nil, errors.New("returned is required")
+	case c.Equivalent == nil:
+		return nil, errors.New("equivalent is required")
+	}
+	return &c, nil
+}
+
+// document returns the policy document held by a case field: the text of
+// a JSON string, such as a URL-encoded document, or otherwise the JSON
+// exactly as written in the file.
+func document(raw json.RawMessage) (string, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`"`)) {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	return string(raw), nil
+}
+
+// TestConformance runs the cases captured from AWS responses, each of
+// which links to the issue that reported it.
+func TestConformance(t *testing.T) {
+	paths := conformancePaths(t, conformanceDir, syntheticDir)
+	if len(paths) == 0 {
+		t.Skipf("No captured conformance cases in %s", conformanceDir)
+	}
+
+	for _, path := range paths {
+		runConformanceCase(t, path, func(c *conformanceCase) error {
+			if c.Issue == "" {
+				return fmt.Errorf("issue is required outside %s", syntheticDir)
+			}
+			return nil
+		})
+	}
+}
+
+// TestConformanceSynthetic runs the hand-written cases.
+func TestConformanceSynthetic(t *testing.T) {
+	paths := conformancePaths(t, syntheticDir, "")
+	if len(paths) == 0 {
+		t.Fatalf("No conformance cases found in %s", syntheticDir)
+	}
+
+	for _, path := range paths {
+		runConformanceCase(t, path, nil)
+	}
+}
+
+// conformancePaths returns the cases in dir, leaving out those in skip.
+func conformancePaths(t *testing.T, dir, skip string) []string {
+	t.Helper()
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == skip {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".json") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return paths
+}
+
+// runConformanceCase runs the case at path as a subtest, after checking
+// it with check if that is not nil.
+func runConformanceCase(t *testing.T, path string, check func(*conformanceCase) error) {
+	name, _ := filepath.Rel(conformanceDir, path)
+	t.Run(filepath.ToSlash(strings.TrimSuffix(name, ".json")), func(t *testing.T) {
+		c, err := readConformanceCase(path)
+		if err == nil && check != nil {
+			err = check(c)
+		}
+		if err != nil {
+			t.Fatalf("Invalid case %s: %s", path, err)
+		}
+		submitted, err := document(c.Submitted)
+		if err != nil {
+			t.Fatalf("Invalid case %s: submitted: %s", path, err)
+		}
+		returned, err := document(c.Returned)
+		if err != nil {
+			t.Fatalf("Invalid case %s: returned: %s", path, err)
+		}
+
+		var opts []Option
+		if c.Options.URLDecoding {
+			opts = append(opts, WithURLDecoding())
+		}
+
+		for _, pair := range [][2]string{{submitted, returned}, {returned, submitted}} {
+			equivalent, err := PoliciesAreEquivalent(pair[0], pair[1], opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if equivalent != *c.Equivalent {
+				t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t\n  Submitted: %s\n   Returned: %s", c.Description, *c.Equivalent, equivalent, submitted, returned)
+			}
+		}
+	})
+}
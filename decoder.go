@@ -0,0 +1,680 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a problem found while decoding a policy document.
+// It records where in the document the problem was found, both as a path
+// of keys and indexes (such as "Statement[1].Condition") and as a position
+// in the source text.
+type ParseError struct {
+	// Path locates the offending value within the document. It is empty
+	// for problems with the document as a whole.
+	Path string
+
+	// Offset is the byte offset of the offending value in the source text.
+	Offset int64
+
+	// Line and Column are the 1-based position of Offset. Column counts
+	// bytes, and is 0 if only the line is known.
+	Line   int
+	Column int
+
+	// Syntax is true if the source text is not valid JSON, or not valid
+	// in its Format.
+	Syntax bool
+
+	// Format names the format of the source text, such as "YAML" or
+	// "HCL", for documents that were not written as JSON. It is empty for
+	// JSON.
+	Format string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.Format != "" {
+		b.WriteString(e.Format)
+		b.WriteString(": ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%s at line %d", e.Err, e.Line)
+	if e.Column > 0 {
+		fmt.Fprintf(&b, ", column %d", e.Column)
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// pathElement is one step of the path to the value being decoded: either
+// an object key or an array index.
+type pathElement struct {
+	key   string
+	index int
+}
+
+// decoder decodes a policy document in a single pass over the JSON
+// tokens, directly into the typed policy model.
+type decoder struct {
+	// src is the full source text and base and end the bounds within src
+	// of the JSON being decoded, so that positions in errors refer to what
+	// the caller passed in.
+	src  string
+	base int64
+	end  int64
+
+	dec  *json.Decoder
+	path []pathElement
+}
+
+// parsePolicy decodes a policy string. An empty string is treated as an
+// empty policy.
+func parsePolicy(policy string) (*policyDocument, error) {
+	start, end := policyBounds(policy)
+	if start == end {
+		return &policyDocument{}, nil
+	}
+
+	return newDecoder(policy, start, end).document()
+}
+
+// parseStatement decodes a single statement object.
+func parseStatement(statement string) (*policyStatement, error) {
+	start, end := trimmedBounds(statement)
+	d := newDecoder(statement, start, end)
+
+	tok, offset, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, d.errorf(offset, "expected statement object, found %s", describe(tok))
+	}
+
+	s, err := d.statement()
+	if err != nil {
+		return nil, err
+	}
+	return s, d.finish()
+}
+
+// newDecoder returns a decoder for the JSON between start and end in src.
+func newDecoder(src string, start, end int) *decoder {
+	d := &decoder{
+		src:  src,
+		base: int64(start),
+		end:  int64(end),
+		dec:  json.NewDecoder(strings.NewReader(src[start:end])),
+	}
+	d.dec.UseNumber()
+	return d
+}
+
+// policyBounds returns the bounds of the JSON document within policy.
+// Although "policy" generally equates to JSON, AWS also has pseudo-JSON
+// policies, such as assume-role policies that can be lists of JSONs. This
+// only handles a one-length list of JSON, whose brackets are excluded.
+func policyBounds(policy string) (int, int) {
+	start, end := trimmedBounds(policy)
+	if end-start >= 2 && policy[start] == '[' && policy[end-1] == ']' {
+		s, e := trimmedBounds(policy[start+1 : end-1])
+		start, end = start+1+s, start+1+e
+	}
+	return start, end
+}
+
+func trimmedBounds(s string) (int, int) {
+	trimmed := strings.TrimLeft(s, " \t\r\n")
+	start := len(s) - len(trimmed)
+	end := start + len(strings.TrimRight(trimmed, " \t\r\n"))
+	return start, end
+}
+
+func (d *decoder) document() (*policyDocument, error) {
+	doc := &policyDocument{}
+
+	tok, offset, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case nil:
+	case json.Delim('{'):
+		err := d.object(func(key string) error {
+			doc.Members = appendMember(doc.Members, key)
+			switch key {
+			case "Version":
+				return d.str(&doc.Version)
+			case "Id":
+				return d.str(&doc.Id)
+			case "Statement":
+				return d.statements(&doc.Statements, &doc.SingleStatement)
+			default:
+				return d.unknown(&doc.Unknown, key)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, d.errorf(offset, "expected policy document object, found %s", describe(tok))
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// finish checks that nothing follows the top-level value.
+func (d *decoder) finish() error {
+	offset := d.nextOffset()
+	if _, err := d.dec.Token(); err != io.EOF {
+		return d.syntaxError(offset, errors.New("invalid data after top-level value"))
+	}
+	return nil
+}
+
+// statements decodes the Statement element, which may be a single
+// statement object or an array of them.
+func (d *decoder) statements(statements *[]*policyStatement, single *bool) error {
+	tok, offset, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case nil:
+		return nil
+	case json.Delim('{'):
+		statement, err := d.statement()
+		if err != nil {
+			return err
+		}
+		*statements = append(*statements, statement)
+		*single = true
+		return nil
+	case json.Delim('['):
+		return d.array(func() error {
+			tok, offset, err := d.token()
+			if err != nil {
+				return err
+			}
+			switch tok {
+			case nil:
+				*statements = append(*statements, nil)
+				return nil
+			case json.Delim('{'):
+				statement, err := d.statement()
+				if err != nil {
+					return err
+				}
+				*statements = append(*statements, statement)
+				return nil
+			default:
+				return d.errorf(offset, "expected statement object, found %s", describe(tok))
+			}
+		})
+	default:
+		return d.errorf(offset, "expected statement object or array, found %s", describe(tok))
+	}
+}
+
+// statement decodes a statement object whose opening brace has already
+// been read.
+func (d *decoder) statement() (*policyStatement, error) {
+	statement := &policyStatement{}
+	err := d.object(func(key string) error {
+		statement.Members = appendMember(statement.Members, key)
+		switch key {
+		case "Sid":
+			return d.str(&statement.Sid)
+		case "Effect":
+			return d.str(&statement.Effect)
+		case "Action":
+			return d.valueList(&statement.Actions)
+		case "NotAction":
+			return d.valueList(&statement.NotActions)
+		case "Resource":
+			return d.valueList(&statement.Resources)
+		case "NotResource":
+			return d.valueList(&statement.NotResources)
+		case "Principal":
+			return d.principals(&statement.Principals)
+		case "NotPrincipal":
+			return d.principals(&statement.NotPrincipals)
+		case "Condition":
+			return d.conditions(&statement.Conditions)
+		default:
+			return d.unknown(&statement.Unknown, key)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// principals decodes a Principal or NotPrincipal element: either a string
+// ("*") or an object mapping principal types to values. Any other value
+// has no principals.
+func (d *decoder) principals(principals **principalsBlock) error {
+	tok, _, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		*principals = nil
+		return nil
+	case string:
+		*principals = &principalsBlock{
+			Types: map[string]valueList{"AWS": {Values: []string{tok}, Single: true}},
+			Bare:  true,
+		}
+		return nil
+	case json.Delim:
+		block := &principalsBlock{Types: map[string]valueList{}}
+		*principals = block
+		if tok == '[' {
+			return d.skipRest()
+		}
+		return d.object(func(key string) error {
+			var values valueList
+			if err := d.valueList(&values); err != nil {
+				return err
+			}
+			block.Types[key] = values
+			return nil
+		})
+	default:
+		*principals = &principalsBlock{}
+		return nil
+	}
+}
+
+// conditions decodes a Condition element, an object mapping condition
+// operators to objects that map condition keys to values.
+func (d *decoder) conditions(conditions *conditionsBlock) error {
+	tok, offset, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case nil:
+		*conditions = nil
+		return nil
+	case json.Delim('{'):
+	default:
+		return d.errorf(offset, "expected condition object, found %s", describe(tok))
+	}
+
+	block := conditionsBlock{}
+	*conditions = block
+	return d.object(func(operator string) error {
+		tok, offset, err := d.token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case nil:
+			block[operator] = nil
+			return nil
+		case json.Delim('{'):
+		default:
+			return d.errorf(offset, "expected condition operator object, found %s", describe(tok))
+		}
+
+		condition := map[string]valueList{}
+		block[operator] = condition
+		return d.object(func(key string) error {
+			var values valueList
+			if err := d.valueList(&values); err != nil {
+				return err
+			}
+			condition[key] = values
+			return nil
+		})
+	})
+}
+
+// valueList decodes an element that may be a single value or an array of
+// values. Objects, nested arrays and nulls within arrays are kept as
+// structured values holding their source text.
+func (d *decoder) valueList(values *valueList) error {
+	tok, offset, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	if tok == nil {
+		*values = valueList{}
+		return nil
+	}
+	if s, kind, ok := scalar(tok); ok {
+		*values = valueList{Values: []string{s}, Single: true}
+		values.setKind(0, kind)
+		return nil
+	}
+	if tok == json.Delim('{') {
+		raw, err := d.raw(offset)
+		*values = valueList{Values: []string{raw}, Kinds: []valueKind{structuredValue}, Single: true}
+		return err
+	}
+
+	list := valueList{Values: []string{}}
+	err = d.array(func() error {
+		tok, offset, err := d.token()
+		if err != nil {
+			return err
+		}
+
+		s, kind, ok := scalar(tok)
+		switch {
+		case ok:
+		case tok == nil:
+			s, kind = "null", structuredValue
+		default:
+			kind = structuredValue
+			if s, err = d.raw(offset); err != nil {
+				return err
+			}
+		}
+		list.Values = append(list.Values, s)
+		list.setKind(len(list.Values)-1, kind)
+		return nil
+	})
+	*values = list
+	return err
+}
+
+// raw consumes the remainder of an object or array that started at offset
+// and returns its source text.
+func (d *decoder) raw(offset int64) (string, error) {
+	if err := d.skipRest(); err != nil {
+		return "", err
+	}
+	return d.src[offset : d.base+d.dec.InputOffset()], nil
+}
+
+// scalar returns the text and kind of a JSON string, number or boolean
+// token. Numbers keep their literal text.
+func scalar(tok json.Token) (string, valueKind, bool) {
+	switch v := tok.(type) {
+	case string:
+		return v, stringValue, true
+	case bool:
+		return strconv.FormatBool(v), boolValue, true
+	case json.Number:
+		return v.String(), numberValue, true
+	default:
+		return "", stringValue, false
+	}
+}
+
+// str decodes a string element. null is treated as an empty string.
+func (d *decoder) str(s *string) error {
+	tok, offset, err := d.token()
+	if err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		*s = v
+		return nil
+	default:
+		if _, ok := v.(json.Delim); ok {
+			if err := d.skipRest(); err != nil {
+				return err
+			}
+		}
+		return d.errorf(offset, "expected string, found %s", describe(tok))
+	}
+}
+
+// object calls f for each key of an object whose opening brace has
+// already been read. f must consume the key's value.
+func (d *decoder) object(f func(key string) error) error {
+	for d.dec.More() {
+		tok, _, err := d.token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		d.path = append(d.path, pathElement{key: key, index: -1})
+		if err := f(key); err != nil {
+			return err
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+
+	_, _, err := d.token()
+	return err
+}
+
+// array calls f for each element of an array whose opening bracket has
+// already been read. f must consume the element.
+func (d *decoder) array(f func() error) error {
+	for i := 0; d.dec.More(); i++ {
+		d.path = append(d.path, pathElement{index: i})
+		if err := f(); err != nil {
+			return err
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+
+	_, _, err := d.token()
+	return err
+}
+
+// unknown consumes the value of a member that isn't parsed, keeping its
+// source text in members under key.
+func (d *decoder) unknown(members *map[string]string, key string) error {
+	offset := d.nextOffset()
+	if err := d.skip(); err != nil {
+		return err
+	}
+	if *members == nil {
+		*members = make(map[string]string)
+	}
+	(*members)[key] = d.src[offset : d.base+d.dec.InputOffset()]
+	return nil
+}
+
+// appendMember appends key to the member names of an object unless it
+// was already written, in which case its later value replaces the
+// earlier one.
+func appendMember(members []string, key string) []string {
+	if slices.Contains(members, key) {
+		return members
+	}
+	return append(members, key)
+}
+
+// skip consumes the next value, whatever its type.
+func (d *decoder) skip() error {
+	tok, _, err := d.token()
+	if err != nil {
+		return err
+	}
+	if _, ok := tok.(json.Delim); ok {
+		return d.skipRest()
+	}
+	return nil
+}
+
+// skipRest consumes the remainder of an object or array whose opening
+// delimiter has already been read.
+func (d *decoder) skipRest() error {
+	for depth := 1; depth > 0; {
+		tok, _, err := d.token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// token reads the next token and returns it along with its offset in the
+// source text. Syntax errors, including running out of input, are returned
+// as a *ParseError.
+func (d *decoder) token() (json.Token, int64, error) {
+	offset := d.nextOffset()
+	tok, err := d.dec.Token()
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// The offending byte is the last one the decoder read, unless
+			// it ran out of input.
+			errOffset := d.base + syntaxErr.Offset
+			if errOffset < d.end {
+				errOffset = max(errOffset-1, d.base)
+			}
+			return nil, offset, d.syntaxError(errOffset, err)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, offset, d.syntaxError(offset, errors.New("unexpected end of JSON input"))
+		}
+		return nil, offset, d.syntaxError(offset, err)
+	}
+	return tok, offset, nil
+}
+
+// nextOffset returns the offset in the source text at which the next
+// token starts, skipping the whitespace and separators json.Decoder has
+// not yet consumed.
+func (d *decoder) nextOffset() int64 {
+	offset := d.base + d.dec.InputOffset()
+	for offset < int64(len(d.src)) {
+		switch d.src[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+			continue
+		}
+		break
+	}
+	return offset
+}
+
+func (d *decoder) errorf(offset int64, format string, args ...interface{}) error {
+	return d.newError(offset, fmt.Errorf(format, args...), false)
+}
+
+func (d *decoder) syntaxError(offset int64, err error) error {
+	return d.newError(offset, err, true)
+}
+
+func (d *decoder) newError(offset int64, err error, syntax bool) *ParseError {
+	line, column := position(d.src, offset)
+	return &ParseError{
+		Path:   d.pathString(),
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		Syntax: syntax,
+		Err:    err,
+	}
+}
+
+func (d *decoder) pathString() string {
+	var b strings.Builder
+	for _, e := range d.path {
+		if e.index >= 0 {
+			fmt.Fprintf(&b, "[%d]", e.index)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(e.key)
+	}
+	return b.String()
+}
+
+// position converts a byte offset in src to a 1-based line and column.
+func position(src string, offset int64) (int, int) {
+	if offset > int64(len(src)) {
+		offset = int64(len(src))
+	}
+	before := src[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := len(before) - strings.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// describe names the JSON type of a token for error messages.
+func describe(tok json.Token) string {
+	switch tok := tok.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case json.Delim:
+		switch tok {
+		case '{':
+			return "object"
+		case '[':
+			return "array"
+		}
+		return strconv.Quote(tok.String())
+	default:
+		return fmt.Sprintf("%T", tok)
+	}
+}
+
+// jsonEqual reports whether two policy strings hold the same JSON value.
+// It is only used when a policy can't be decoded, so that a document is
+// still reported as equivalent to an identical copy of itself.
+func jsonEqual(policy1, policy2 string) bool {
+	start1, end1 := policyBounds(policy1)
+	start2, end2 := policyBounds(policy2)
+	policy1, policy2 = policy1[start1:end1], policy2[start2:end2]
+	if policy1 == policy2 {
+		return true
+	}
+
+	var v1, v2 interface{}
+	if err := json.Unmarshal([]byte(policy1), &v1); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(policy2), &v2); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(v1, v2)
+}
@@ -0,0 +1,166 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePolicyErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy string
+		path   string
+		line   int
+		column int
+		syntax bool
+	}{
+		{
+			name:   "Invalid JSON",
+			policy: policyTest0,
+			path:   "Statement[0]",
+			line:   5,
+			column: 3,
+			syntax: true,
+		},
+		{
+			name:   "Truncated JSON",
+			policy: `{"Version": "2012-10-17", "Statement": [`,
+			path:   "Statement[0]",
+			line:   1,
+			column: 41,
+			syntax: true,
+		},
+		{
+			name:   "Trailing data",
+			policy: `{"Version": "2012-10-17"} {}`,
+			line:   1,
+			column: 27,
+			syntax: true,
+		},
+		{
+			name:   "Statement of the wrong type",
+			policy: policyTest31,
+			path:   "Statement",
+			line:   3,
+			column: 16,
+		},
+		{
+			name: "Effect of the wrong type",
+			policy: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+    {"Effect": true, "Action": "s3:*", "Resource": "*"}
+  ]
+}`,
+			path:   "Statement[1].Effect",
+			line:   5,
+			column: 16,
+		},
+		{
+			name: "Condition operator of the wrong type",
+			policy: `{
+  "Statement": {
+    "Effect": "Allow",
+    "Condition": {"StringEquals": ["a"]}
+  }
+}`,
+			path:   "Statement.Condition.StringEquals",
+			line:   4,
+			column: 35,
+		},
+		{
+			name:   "Position within a one-length list",
+			policy: "  [ {\"Version\": 42} ]",
+			path:   "Version",
+			line:   1,
+			column: 17,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parsePolicy(tc.policy)
+			if err == nil {
+				t.Fatal("Expected error, none produced")
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *ParseError, got %T: %s", err, err)
+			}
+
+			if parseErr.Path != tc.path {
+				t.Errorf("Bad path\n  Expected: %q\n       Got: %q", tc.path, parseErr.Path)
+			}
+			if parseErr.Line != tc.line || parseErr.Column != tc.column {
+				t.Errorf("Bad position\n  Expected: %d:%d\n       Got: %d:%d (%s)", tc.line, tc.column, parseErr.Line, parseErr.Column, err)
+			}
+			if parseErr.Syntax != tc.syntax {
+				t.Errorf("Bad syntax flag\n  Expected: %t\n       Got: %t", tc.syntax, parseErr.Syntax)
+			}
+		})
+	}
+}
+
+func TestPolicyEquivalenceErrorWrapping(t *testing.T) {
+	_, err := PoliciesAreEquivalent(policyTest1, policyTest31)
+	if err == nil {
+		t.Fatal("Expected error, none produced")
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected *ParseError, got %T: %s", err, err)
+	}
+	if parseErr.Path != "Statement" {
+		t.Fatalf("Bad path: %q", parseErr.Path)
+	}
+	if got, want := err.Error(), "parsing policy 2: Statement: expected statement object or array, found number at line 3, column 16"; got != want {
+		t.Fatalf("Bad error\n  Expected: %s\n       Got: %s", want, got)
+	}
+}
+
+func TestParsePolicyValueLists(t *testing.T) {
+	doc, err := parsePolicy(`{
+  "Statement": [
+    {
+      "Action": ["s3:GetObject", 42, true, 1.5],
+      "NotAction": "s3:PutObject",
+      "Resource": [{"a": 1}],
+      "NotResource": null,
+      "Principal": ["ignored"],
+      "Unknown": {"ignored": [1, 2, {"x": null}]}
+    },
+    null
+  ]
+}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(doc.Statements) != 2 || doc.Statements[1] != nil {
+		t.Fatalf("Bad statements: %v", doc.Statements)
+	}
+
+	statement := doc.Statements[0]
+	if got := statement.Actions.set(); !stringSlicesEqualIgnoreOrder(got, []string{"s3:GetObject", "42", "true", "1.5"}) || statement.Actions.Single {
+		t.Errorf("Bad Action: %#v", statement.Actions)
+	}
+	if !statement.NotActions.Single || len(statement.NotActions.Values) != 1 {
+		t.Errorf("Bad NotAction: %#v", statement.NotActions)
+	}
+	if len(statement.Resources.Values) != 1 || statement.Resources.Values[0] != `{"a": 1}` || statement.Resources.kind(0) != structuredValue {
+		t.Errorf("Bad Resource: %#v", statement.Resources)
+	}
+	if statement.NotResources.Values != nil {
+		t.Errorf("Bad NotResource: %#v", statement.NotResources)
+	}
+	if statement.Principals == nil || len(statement.Principals.Types) != 0 {
+		t.Errorf("Bad Principal: %#v", statement.Principals)
+	}
+}
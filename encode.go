@@ -0,0 +1,532 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MarshalOption configures how a Policy or Statement is written as JSON.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	preserveShape bool
+	arrays        bool
+
+	// collapse modifies preserveShape to write single member arrays as
+	// single values and to omit empty elements, as some AWS services do.
+	collapse bool
+
+	indented       bool
+	prefix, indent string
+}
+
+func newMarshalOptions(base marshalOptions, opts []MarshalOption) marshalOptions {
+	o := base
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// PreserveShape returns a MarshalOption that writes every element in the
+// shape it was parsed in: single values stay single values, arrays stay
+// arrays with their original order and duplicates, numbers keep their
+// literal text and empty elements are kept. The members of the document
+// and of each statement are written in the order they were parsed in,
+// and unknown members are written back as they were. Principal types,
+// condition operators and condition keys are still written in sorted
+// order, and whitespace is not kept.
+func PreserveShape() MarshalOption {
+	return func(o *marshalOptions) {
+		o.preserveShape = true
+	}
+}
+
+// CanonicalShape returns a MarshalOption that writes the canonical form
+// of the policy, undoing an earlier PreserveShape. This is the default.
+func CanonicalShape() MarshalOption {
+	return func(o *marshalOptions) {
+		o.preserveShape = false
+	}
+}
+
+// ArraysEverywhere returns a MarshalOption that writes every list, such
+// as Action, Statement or a condition's values, as an array, even when it
+// has a single member.
+func ArraysEverywhere() MarshalOption {
+	return func(o *marshalOptions) {
+		o.arrays = true
+	}
+}
+
+// CollapseSingleValues returns a MarshalOption that writes lists with a
+// single member as a single value, as AWS does, undoing an earlier
+// ArraysEverywhere. This is the default.
+func CollapseSingleValues() MarshalOption {
+	return func(o *marshalOptions) {
+		o.arrays = false
+	}
+}
+
+// Indent returns a MarshalOption that writes the JSON indented, as
+// json.MarshalIndent does, beginning each line with prefix and indenting
+// each level once more with indent.
+func Indent(prefix, indent string) MarshalOption {
+	return func(o *marshalOptions) {
+		o.indented = true
+		o.prefix, o.indent = prefix, indent
+	}
+}
+
+// Compact returns a MarshalOption that writes the JSON without
+// whitespace, undoing an earlier Indent. This is the default.
+func Compact() MarshalOption {
+	return func(o *marshalOptions) {
+		o.indented = false
+		o.prefix, o.indent = "", ""
+	}
+}
+
+// Format parses policy and writes it for display. Elements are written in
+// the order the IAM console uses (Version, Id and Statement, then Sid,
+// Effect, Principal, NotPrincipal, Action, NotAction, Resource,
+// NotResource and Condition), or in their original order with
+// PreserveShape, and indented by two spaces. opts are applied
+// after the default indentation, so that for example Indent("", "\t")
+// indents with tabs. Problems with the document are reported as a
+// *ParseError.
+func Format(policy string, opts ...MarshalOption) (string, error) {
+	p, err := ParsePolicy(policy)
+	if err != nil {
+		return "", err
+	}
+
+	formatted, err := p.Marshal(append([]MarshalOption{Indent("", "  ")}, opts...)...)
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+// Marshal writes the policy as JSON. By default the canonical form is
+// written: a compact document with its elements in a fixed order,
+// Statement written as an array in document order, and every other list
+// sorted, free of duplicates and written as a single value when it has
+// one member. Empty elements are omitted, and a "Principal": "*" element
+// stays a string, as AWS and the IAM console write it, unless
+// ArraysEverywhere is given. Either form is equivalent to the parsed
+// policy.
+//
+// Options given to Marshal are applied after those the policy was given
+// by WithMarshalOptions.
+func (p *Policy) Marshal(opts ...MarshalOption) ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+
+	e := encoder{marshalOptions: newMarshalOptions(p.marshal, opts)}
+	e.document(p.document())
+	return e.result()
+}
+
+// MarshalJSON implements json.Marshaler, writing the policy as Marshal
+// does with the options given to WithMarshalOptions.
+func (p *Policy) MarshalJSON() ([]byte, error) {
+	return p.Marshal()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, parsing a policy document as
+// ParsePolicy does. The policy's marshal options are kept.
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	doc, err := parsePolicy(string(data))
+	if err != nil {
+		return err
+	}
+	p.doc = doc
+	return nil
+}
+
+// WithMarshalOptions returns a copy of the policy that MarshalJSON writes
+// with opts, for use with json.Marshal. Statements of the copy inherit
+// the options.
+func (p *Policy) WithMarshalOptions(opts ...MarshalOption) *Policy {
+	return &Policy{doc: p.doc, marshal: newMarshalOptions(p.marshal, opts)}
+}
+
+// Marshal writes the statement as JSON, in the same form as Policy.Marshal
+// writes statements.
+func (s *Statement) Marshal(opts ...MarshalOption) ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	e := encoder{marshalOptions: newMarshalOptions(s.marshal, opts)}
+	e.statement(s.policyStatement())
+	return e.result()
+}
+
+// MarshalJSON implements json.Marshaler, writing the statement as Marshal
+// does with the marshal options of the policy it came from.
+func (s *Statement) MarshalJSON() ([]byte, error) {
+	return s.Marshal()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, parsing a single statement
+// object. Problems with the statement are reported as a *ParseError.
+func (s *Statement) UnmarshalJSON(data []byte) error {
+	statement, err := parseStatement(string(data))
+	if err != nil {
+		return err
+	}
+	s.statement = statement
+	return nil
+}
+
+// encoder writes policies as JSON.
+type encoder struct {
+	bytes.Buffer
+	marshalOptions
+}
+
+// result returns what has been written, indented if requested.
+func (e *encoder) result() ([]byte, error) {
+	if !e.indented {
+		return e.Bytes(), nil
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, e.Bytes(), e.prefix, e.indent); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// documentMembers and statementMembers are the parsed members of a
+// document and a statement, in the order they are written in.
+var (
+	documentMembers  = []string{"Version", "Id", "Statement"}
+	statementMembers = []string{"Sid", "Effect", "Principal", "NotPrincipal", "Action", "NotAction", "Resource", "NotResource", "Condition"}
+)
+
+func (e *encoder) document(doc *policyDocument) {
+	e.WriteByte('{')
+	var o object
+	for _, member := range e.members(documentMembers, doc.Members) {
+		switch member {
+		case "Version":
+			if doc.Version != "" {
+				e.key(&o, "Version")
+				e.str(doc.Version)
+			}
+		case "Id":
+			if doc.Id != "" {
+				e.key(&o, "Id")
+				e.str(doc.Id)
+			}
+		case "Statement":
+			e.statements(&o, doc)
+		default:
+			e.unknown(&o, member, doc.Unknown)
+		}
+	}
+	e.WriteByte('}')
+}
+
+func (e *encoder) statements(o *object, doc *policyDocument) {
+	if e.keepEmpty() && !e.arrays && doc.SingleStatement && len(doc.Statements) == 1 {
+		e.key(o, "Statement")
+		e.statement(doc.Statements[0])
+	} else if doc.Statements != nil {
+		e.key(o, "Statement")
+		e.WriteByte('[')
+		for i, statement := range doc.Statements {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			e.statement(statement)
+		}
+		e.WriteByte(']')
+	}
+}
+
+func (e *encoder) statement(statement *policyStatement) {
+	if statement == nil {
+		e.WriteString("null")
+		return
+	}
+
+	e.WriteByte('{')
+	var o object
+	for _, member := range e.members(statementMembers, statement.Members) {
+		switch member {
+		case "Sid":
+			if statement.Sid != "" {
+				e.key(&o, "Sid")
+				e.str(statement.Sid)
+			}
+		case "Effect":
+			if statement.Effect != "" {
+				e.key(&o, "Effect")
+				e.str(statement.Effect)
+			}
+		case "Principal":
+			e.principals(&o, "Principal", statement.Principals)
+		case "NotPrincipal":
+			e.principals(&o, "NotPrincipal", statement.NotPrincipals)
+		case "Action":
+			e.element(&o, "Action", statement.Actions)
+		case "NotAction":
+			e.element(&o, "NotAction", statement.NotActions)
+		case "Resource":
+			e.element(&o, "Resource", statement.Resources)
+		case "NotResource":
+			e.element(&o, "NotResource", statement.NotResources)
+		case "Condition":
+			e.conditions(&o, statement.Conditions)
+		default:
+			e.unknown(&o, member, statement.Unknown)
+		}
+	}
+	e.WriteByte('}')
+}
+
+// members returns the order in which the members of an object are
+// written. When the exact shape is written this is the order they were
+// parsed in, followed by any parsed members that weren't in the source,
+// such as those of a statement built with a Builder. Otherwise it is
+// fixed, which leaves out unknown members.
+func (e *encoder) members(fixed, parsed []string) []string {
+	if !e.keepEmpty() || parsed == nil {
+		return fixed
+	}
+
+	members := slices.Clone(parsed)
+	for _, member := range fixed {
+		if !slices.Contains(parsed, member) {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// unknown writes a member that isn't parsed from its source text.
+func (e *encoder) unknown(o *object, member string, unknown map[string]string) {
+	text, ok := unknown[member]
+	if !ok {
+		return
+	}
+	e.key(o, member)
+	if err := json.Compact(&e.Buffer, []byte(text)); err != nil {
+		e.WriteString(text)
+	}
+}
+
+// keepEmpty reports whether the exact shape of the document is written,
+// including empty elements.
+func (e *encoder) keepEmpty() bool {
+	return e.preserveShape && !e.collapse
+}
+
+// omit reports whether an element with values is left out of the output.
+// When the exact shape is written only missing elements are omitted, and
+// otherwise empty ones are too.
+func (e *encoder) omit(values valueList) bool {
+	if e.keepEmpty() {
+		return values.Values == nil
+	}
+	return len(values.Values) == 0
+}
+
+func (e *encoder) principals(o *object, name string, principals *principalsBlock) {
+	if principals == nil {
+		return
+	}
+	if principals.Bare && !e.arrays {
+		e.key(o, name)
+		e.str(principals.Types["AWS"].Values[0])
+		return
+	}
+
+	types := make([]string, 0, len(principals.Types))
+	for principalType, values := range principals.Types {
+		if !e.omit(values) {
+			types = append(types, principalType)
+		}
+	}
+	if len(types) == 0 && !e.keepEmpty() {
+		return
+	}
+	sort.Strings(types)
+
+	e.key(o, name)
+	e.WriteByte('{')
+	var inner object
+	for _, principalType := range types {
+		e.key(&inner, principalType)
+		e.values(principals.Types[principalType])
+	}
+	e.WriteByte('}')
+}
+
+func (e *encoder) element(o *object, name string, values valueList) {
+	if e.omit(values) {
+		return
+	}
+	e.key(o, name)
+	e.values(values)
+}
+
+func (e *encoder) conditions(o *object, conditions conditionsBlock) {
+	operators := make([]string, 0, len(conditions))
+	for operator, condition := range conditions {
+		if e.keepEmpty() {
+			operators = append(operators, operator)
+			continue
+		}
+		for _, values := range condition {
+			if !e.omit(values) {
+				operators = append(operators, operator)
+				break
+			}
+		}
+	}
+	if len(operators) == 0 && (conditions == nil || !e.keepEmpty()) {
+		return
+	}
+	sort.Strings(operators)
+
+	e.key(o, "Condition")
+	e.WriteByte('{')
+	var operatorObject object
+	for _, operator := range operators {
+		e.key(&operatorObject, operator)
+		condition := conditions[operator]
+		if condition == nil {
+			e.WriteString("null")
+			continue
+		}
+
+		e.WriteByte('{')
+		var keyObject object
+		for _, key := range slices.Sorted(maps.Keys(condition)) {
+			values := condition[key]
+			if e.omit(values) {
+				continue
+			}
+			e.key(&keyObject, key)
+			e.values(values)
+		}
+		e.WriteByte('}')
+	}
+	e.WriteByte('}')
+}
+
+// values writes a list. In canonical form the distinct members are
+// sorted by the form they are compared in and written as a single value
+// or an array. A single value that is itself an array is kept in an
+// array, as it would otherwise be read as a list.
+func (e *encoder) values(values valueList) {
+	if e.preserveShape {
+		single := values.Single || (e.collapse && !isArray(values, 0))
+		if single && len(values.Values) == 1 && !e.arrays {
+			e.value(values, 0)
+			return
+		}
+		e.WriteByte('[')
+		for i := range values.Values {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			e.value(values, i)
+		}
+		e.WriteByte(']')
+		return
+	}
+
+	set := values.set()
+	order := make([]int, 0, len(set))
+	seen := make(map[string]struct{}, len(set))
+	for i, member := range set {
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		order = append(order, i)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return set[order[i]] < set[order[j]]
+	})
+
+	array := e.arrays || len(order) > 1 || isArray(values, order[0])
+	if array {
+		e.WriteByte('[')
+	}
+	for n, i := range order {
+		if n > 0 {
+			e.WriteByte(',')
+		}
+		e.value(values, i)
+	}
+	if array {
+		e.WriteByte(']')
+	}
+}
+
+func isArray(values valueList, i int) bool {
+	return values.kind(i) == structuredValue && strings.HasPrefix(values.Values[i], "[")
+}
+
+// value writes the value at index i in the form it was decoded from.
+// Numbers are written in canonical form unless the shape is preserved.
+func (e *encoder) value(values valueList, i int) {
+	v := values.Values[i]
+	switch values.kind(i) {
+	case numberValue:
+		if !e.preserveShape {
+			v = canonicalNumber(v)
+		}
+		e.WriteString(v)
+	case boolValue:
+		e.WriteString(v)
+	case structuredValue:
+		if err := json.Compact(&e.Buffer, []byte(v)); err != nil {
+			e.WriteString(v)
+		}
+	default:
+		e.str(v)
+	}
+}
+
+// object tracks whether a comma is needed before the next key.
+type object struct {
+	started bool
+}
+
+func (e *encoder) key(o *object, key string) {
+	if o.started {
+		e.WriteByte(',')
+	}
+	o.started = true
+	e.str(key)
+	e.WriteByte(':')
+}
+
+// str writes a JSON string. Unlike json.Marshal, it doesn't escape HTML
+// characters, which are common in condition values.
+func (e *encoder) str(s string) {
+	enc := json.NewEncoder(&e.Buffer)
+	enc.SetEscapeHTML(false)
+	// Encoding a string can't fail.
+	_ = enc.Encode(s)
+	e.Truncate(e.Len() - 1)
+}
@@ -0,0 +1,491 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyMarshalJSON(t *testing.T) {
+	policy, err := ParsePolicy(`{
+  "Statement": {
+    "Effect": "Allow",
+    "Resource": ["arn:aws:s3:::b", "arn:aws:s3:::a", "arn:aws:s3:::b"],
+    "Action": "s3:<Get>&Put",
+    "Principal": "*",
+    "Condition": {
+      "StringEquals": {"aws:SourceVpc": ["vpc-1"], "aws:Empty": []},
+      "NumericEquals": {"custom:Count": [1.50, 15e-1, 2]},
+      "ForAnyValue:StringLike": {"custom:Structured": {"b": [2, 1], "a": true}},
+      "Bool": {}
+    },
+    "Unknown": "dropped"
+  },
+  "Id": "id",
+  "Version": "2012-10-17"
+}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	got, err := policy.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := `{"Version":"2012-10-17","Id":"id","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:<Get>&Put","Resource":["arn:aws:s3:::a","arn:aws:s3:::b"],"Condition":{"ForAnyValue:StringLike":{"custom:Structured":{"b":[2,1],"a":true}},"NumericEquals":{"custom:Count":[1.5,2]},"StringEquals":{"aws:SourceVpc":"vpc-1"}}}]}`
+	if string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+}
+
+func TestPolicyMarshalJSONRoundTrip(t *testing.T) {
+	policies := map[string]string{
+		"policyTest1":   policyTest1,
+		"policyTest2a":  policyTest2a,
+		"policyTest2b":  policyTest2b,
+		"policyTest3a":  policyTest3a,
+		"policyTest3b":  policyTest3b,
+		"policyTest4a":  policyTest4a,
+		"policyTest4b":  policyTest4b,
+		"policyTest5a":  policyTest5a,
+		"policyTest5b":  policyTest5b,
+		"policyTest6a":  policyTest6a,
+		"policyTest6b":  policyTest6b,
+		"policyTest7a":  policyTest7a,
+		"policyTest7b":  policyTest7b,
+		"policyTest8a":  policyTest8a,
+		"policyTest8b":  policyTest8b,
+		"policyTest9a":  policyTest9a,
+		"policyTest9b":  policyTest9b,
+		"policyTest10a": policyTest10a,
+		"policyTest10b": policyTest10b,
+		"policyTest11a": policyTest11a,
+		"policyTest11b": policyTest11b,
+		"policyTest12a": policyTest12a,
+		"policyTest12b": policyTest12b,
+		"policyTest13a": policyTest13a,
+		"policyTest13b": policyTest13b,
+		"policyTest14a": policyTest14a,
+		"policyTest14b": policyTest14b,
+		"policyTest15a": policyTest15a,
+		"policyTest15b": policyTest15b,
+		"policyTest16a": policyTest16a,
+		"policyTest16b": policyTest16b,
+		"policyTest17a": policyTest17a,
+		"policyTest17b": policyTest17b,
+		"policyTest18a": policyTest18a,
+		"policyTest18b": policyTest18b,
+		"policyTest19a": policyTest19a,
+		"policyTest19b": policyTest19b,
+		"policyTest20a": policyTest20a,
+		"policyTest20b": policyTest20b,
+		"policyTest21a": policyTest21a,
+		"policyTest21b": policyTest21b,
+		"policyTest22a": policyTest22a,
+		"policyTest22b": policyTest22b,
+		"policyTest23a": policyTest23a,
+		"policyTest23b": policyTest23b,
+		"policyTest24a": policyTest24a,
+		"policyTest24b": policyTest24b,
+		"policyTest25a": policyTest25a,
+		"policyTest25b": policyTest25b,
+		"policyTest26a": policyTest26a,
+		"policyTest26b": policyTest26b,
+		"policyTest27a": policyTest27a,
+		"policyTest27b": policyTest27b,
+		"policyTest28a": policyTest28a,
+		"policyTest28b": policyTest28b,
+		"policyTest29a": policyTest29a,
+		"policyTest29b": policyTest29b,
+		"policyTest30":  policyTest30,
+		"policyTest31":  policyTest31,
+		"policyTest32":  policyTest32,
+		"policyTest33":  policyTest33,
+		"policyTest34a": policyTest34a,
+		"policyTest34b": policyTest34b,
+		"policyTest34c": policyTest34c,
+		"policyTest35a": policyTest35a,
+		"policyTest35b": policyTest35b,
+		"policyTest36a": policyTest36a,
+		"policyTest36b": policyTest36b,
+		"policyTest37a": policyTest37a,
+		"policyTest37b": policyTest37b,
+		"policyTest38a": policyTest38a,
+		"policyTest38b": policyTest38b,
+		"policyTest39a": policyTest39a,
+		"policyTest39b": policyTest39b,
+		"policyTest40a": policyTest40a,
+		"policyTest40b": policyTest40b,
+		"policyTest41a": policyTest41a,
+		"policyTest41b": policyTest41b,
+		"policyTest42a": policyTest42a,
+		"policyTest42b": policyTest42b,
+		"policyTest43a": policyTest43a,
+		"policyTest43b": policyTest43b,
+		"policyTest44a": policyTest44a,
+		"policyTest44b": policyTest44b,
+		"policyTest45a": policyTest45a,
+		"policyTest45b": policyTest45b,
+		"policyTest45c": policyTest45c,
+		"policyTest46a": policyTest46a,
+		"policyTest46b": policyTest46b,
+		"policyTest47a": policyTest47a,
+		"policyTest47b": policyTest47b,
+		"policyTest47c": policyTest47c,
+		"policyTest48a": policyTest48a,
+		"policyTest48b": policyTest48b,
+		"policyTest49a": policyTest49a,
+		"policyTest49b": policyTest49b,
+		"policyTest49c": policyTest49c,
+		"policyTest50a": policyTest50a,
+		"policyTest50b": policyTest50b,
+		"policyTest51a": policyTest51a,
+		"policyTest51b": policyTest51b,
+		"policyTest52a": policyTest52a,
+		"policyTest52b": policyTest52b,
+		"policyTest53a": policyTest53a,
+		"policyTest53b": policyTest53b,
+		"policyTest54a": policyTest54a,
+		"policyTest54b": policyTest54b,
+		"policyTest55a": policyTest55a,
+		"policyTest55b": policyTest55b,
+		"policyTest56a": policyTest56a,
+		"policyTest56b": policyTest56b,
+		"policyTest57a": policyTest57a,
+		"policyTest57b": policyTest57b,
+		"policyTest57c": policyTest57c,
+		"policyTest58a": policyTest58a,
+		"policyTest58b": policyTest58b,
+		"policyTest59a": policyTest59a,
+		"policyTest59b": policyTest59b,
+		"policyTest59c": policyTest59c,
+		"policyTest60a": policyTest60a,
+		"policyTest60b": policyTest60b,
+	}
+
+	shapes := map[string]MarshalOption{
+		"canonical": CanonicalShape(),
+		"preserved": PreserveShape(),
+		"arrays":    ArraysEverywhere(),
+	}
+
+	for name, policy := range policies {
+		for shape, opt := range shapes {
+			t.Run(name+"/"+shape, func(t *testing.T) {
+				parsed, err := ParsePolicy(policy)
+				if err != nil {
+					t.Skipf("Not a policy: %s", err)
+				}
+
+				marshaled, err := parsed.Marshal(opt)
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+
+				equivalent, err := PoliciesAreEquivalent(policy, string(marshaled))
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if !equivalent {
+					t.Fatalf("Marshaled JSON not equivalent\n  Original: %s\n Marshaled: %s", policy, marshaled)
+				}
+
+				reparsed, err := ParsePolicy(string(marshaled))
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				again, err := reparsed.Marshal(opt)
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if string(again) != string(marshaled) {
+					t.Fatalf("Marshaled JSON not stable\n  Expected: %s\n       Got: %s", marshaled, again)
+				}
+			})
+		}
+	}
+}
+
+func TestPolicyMarshalPreserveShape(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::b","arn:aws:s3:::a","arn:aws:s3:::b"],"NotResource":[],"Condition":{"Bool":{},"NumericEquals":{"custom:Count":[1.50,15e-1]},"StringEquals":{"aws:SourceVpc":"vpc-1"}}}}`
+
+	parsed, err := ParsePolicy(policy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	got, err := parsed.Marshal(PreserveShape())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(got) != policy {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", policy, got)
+	}
+}
+
+func TestPolicyMarshalPreserveShapeUnknownMembers(t *testing.T) {
+	policy := `{"Statement":[{"Resource":"*","Foo":"bar","Effect":"Allow","Action":"s3:GetObject"}],"Extra":1,"Version":"2012-10-17"}`
+
+	parsed, err := ParsePolicy(policy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	got, err := parsed.Marshal(PreserveShape())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(got) != policy {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", policy, got)
+	}
+
+	got, err = parsed.Marshal()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`; string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+}
+
+func TestPolicyJSONInterfaces(t *testing.T) {
+	type document struct {
+		Name   string
+		Policy *Policy
+	}
+
+	var doc document
+	err := json.Unmarshal([]byte(`{
+  "Name": "example",
+  "Policy": {
+    "Version": "2012-10-17",
+    "Statement": [
+      {"Sid": "One", "Effect": "Allow", "Action": ["s3:GetObject"], "Resource": "*"},
+      {"Sid": "Two", "Effect": "Allow", "Action": "s3:PutObject", "Resource": ["*"]}
+    ]
+  }
+}`), &doc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var statement Statement
+	if err := json.Unmarshal([]byte(`{"Sid": "Two", "Effect": "Deny", "Action": ["s3:PutObject", "s3:DeleteObject"], "Resource": "*"}`), &statement); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	doc.Policy = doc.Policy.WithStatement(1, &statement).WithMarshalOptions(PreserveShape())
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := `{"Name":"example","Policy":{"Version":"2012-10-17","Statement":[{"Sid":"One","Effect":"Allow","Action":["s3:GetObject"],"Resource":"*"},{"Sid":"Two","Effect":"Deny","Action":["s3:PutObject","s3:DeleteObject"],"Resource":"*"}]}}`
+	if string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+
+	statementJSON, err := json.Marshal(doc.Policy.Statements()[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := `{"Sid":"One","Effect":"Allow","Action":["s3:GetObject"],"Resource":"*"}`; string(statementJSON) != want {
+		t.Fatalf("Bad statement JSON\n  Expected: %s\n       Got: %s", want, statementJSON)
+	}
+}
+
+func TestZeroPolicy(t *testing.T) {
+	var policy Policy
+	if got := policy.Version(); got != "" {
+		t.Fatalf("Bad: Version\n  Expected: \"\"\n       Got: %q", got)
+	}
+	if got := policy.Id(); got != "" {
+		t.Fatalf("Bad: Id\n  Expected: \"\"\n       Got: %q", got)
+	}
+	if got := policy.Statements(); got != nil {
+		t.Fatalf("Bad: Statements\n  Expected: nil\n       Got: %v", got)
+	}
+	for range policy.All() {
+		t.Fatalf("Bad: All yielded a statement")
+	}
+
+	got, err := json.Marshal(&struct{ Policy Policy }{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := `{"Policy":{}}`; string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+
+	got, err = policy.Marshal(PreserveShape())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := `{}`; string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+
+	empty, err := ParsePolicy(`{}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !policy.EquivalentTo(empty) {
+		t.Fatalf("Bad: zero Policy is not equivalent to {}")
+	}
+
+	var nilPolicy *Policy
+	if nilPolicy.Version() != "" || nilPolicy.Id() != "" || nilPolicy.Statements() != nil {
+		t.Fatalf("Bad: nil Policy is not empty")
+	}
+
+	var statement Statement
+	if got := statement.Effect(); got != "" {
+		t.Fatalf("Bad: Effect\n  Expected: \"\"\n       Got: %q", got)
+	}
+	if got := statement.Actions(); got != nil {
+		t.Fatalf("Bad: Actions\n  Expected: nil\n       Got: %v", got)
+	}
+	if MatchesAction("s3:GetObject")(&statement) {
+		t.Fatalf("Bad: zero Statement matches an action")
+	}
+	got, err = json.Marshal(&statement)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := `{}`; string(got) != want {
+		t.Fatalf("Bad JSON\n  Expected: %s\n       Got: %s", want, got)
+	}
+}
+
+func TestStatementUnmarshalJSONErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "Not an object",
+			data: `["s3:GetObject"]`,
+			err:  "expected statement object, found array at line 1, column 1",
+		},
+		{
+			name: "Bad element",
+			data: `{"Effect": "Allow", "Condition": []}`,
+			err:  "Condition: expected condition object, found array at line 1, column 34",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var statement Statement
+			err := statement.UnmarshalJSON([]byte(tc.data))
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("Bad error\n  Expected: %s\n       Got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	policy := `{"Statement":[{"Condition":{"StringEquals":{"aws:SourceVpc":"vpc-1"}},"Resource":"*","Action":["s3:PutObject","s3:GetObject"],"Principal":"*","Effect":"Allow","Sid":"One"}],"Version":"2012-10-17"}`
+
+	cases := []struct {
+		name     string
+		opts     []MarshalOption
+		expected string
+	}{
+		{
+			name: "Default",
+			expected: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "One",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": [
+        "s3:GetObject",
+        "s3:PutObject"
+      ],
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "aws:SourceVpc": "vpc-1"
+        }
+      }
+    }
+  ]
+}`,
+		},
+		{
+			name: "Arrays everywhere",
+			opts: []MarshalOption{Indent("", "\t"), ArraysEverywhere()},
+			expected: `{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Sid": "One",
+			"Effect": "Allow",
+			"Principal": {
+				"AWS": [
+					"*"
+				]
+			},
+			"Action": [
+				"s3:GetObject",
+				"s3:PutObject"
+			],
+			"Resource": [
+				"*"
+			],
+			"Condition": {
+				"StringEquals": {
+					"aws:SourceVpc": [
+						"vpc-1"
+					]
+				}
+			}
+		}
+	]
+}`,
+		},
+		{
+			name:     "Preserved shape",
+			opts:     []MarshalOption{Compact(), PreserveShape()},
+			expected: `{"Statement":[{"Condition":{"StringEquals":{"aws:SourceVpc":"vpc-1"}},"Resource":"*","Action":["s3:PutObject","s3:GetObject"],"Principal":"*","Effect":"Allow","Sid":"One"}],"Version":"2012-10-17"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Format(policy, tc.opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if got != tc.expected {
+				t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s\n", tc.name, tc.expected, got)
+			}
+
+			equivalent, err := PoliciesAreEquivalent(policy, got)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !equivalent {
+				t.Fatal("Expected formatted policy to be equivalent")
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	_, err := Format(policyTest31)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected *ParseError, got %T: %v", err, err)
+	}
+}
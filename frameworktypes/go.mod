@@ -0,0 +1,25 @@
+module github.com/hashicorp/awspolicyequivalence/frameworktypes
+
+go 1.23.0
+
+require (
+	github.com/hashicorp/awspolicyequivalence v1.8.0
+	github.com/hashicorp/terraform-plugin-framework v1.15.1
+	github.com/hashicorp/terraform-plugin-go v0.27.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.36.1 // indirect
+	github.com/fatih/color v1.16.0 // indirect
+	github.com/hashicorp/go-hclog v1.6.3 // indirect
+	github.com/hashicorp/terraform-plugin-log v0.9.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mitchellh/go-testing-interface v1.14.1 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+)
+
+// Ignored outside this repository. See "Nested modules" in README.md.
+replace github.com/hashicorp/awspolicyequivalence => ../
@@ -0,0 +1,79 @@
+// Package frameworktypes provides a terraform-plugin-framework custom type
+// for attributes holding an AWS policy document. Values are validated as
+// policies, and a change to a value that PoliciesAreEquivalent considers
+// equivalent to the prior one is not reported as a difference.
+//
+// Declare an attribute with the type once:
+//
+//	"policy": schema.StringAttribute{
+//		CustomType: frameworktypes.PolicyType{},
+//		Required:   true,
+//	},
+//
+// and read it into a frameworktypes.Policy field.
+package frameworktypes
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+var _ basetypes.StringTypable = PolicyType{}
+
+// PolicyType is the attribute type of an AWS policy document. Policy is the
+// associated value type.
+type PolicyType struct {
+	basetypes.StringType
+}
+
+// String returns a human readable string of the type name.
+func (t PolicyType) String() string {
+	return "frameworktypes.PolicyType"
+}
+
+// ValueType returns the value type of this type.
+func (t PolicyType) ValueType(ctx context.Context) attr.Value {
+	return Policy{}
+}
+
+// Equal returns true if the given type is equivalent.
+func (t PolicyType) Equal(o attr.Type) bool {
+	other, ok := o.(PolicyType)
+	if !ok {
+		return false
+	}
+	return t.StringType.Equal(other.StringType)
+}
+
+// ValueFromString returns a Policy holding the given string value.
+func (t PolicyType) ValueFromString(ctx context.Context, in basetypes.StringValue) (basetypes.StringValuable, diag.Diagnostics) {
+	return Policy{StringValue: in}, nil
+}
+
+// ValueFromTerraform returns a Policy holding the given Terraform value.
+func (t PolicyType) ValueFromTerraform(ctx context.Context, in tftypes.Value) (attr.Value, error) {
+	attrValue, err := t.StringType.ValueFromTerraform(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	stringValue, ok := attrValue.(basetypes.StringValue)
+	if !ok {
+		return nil, fmt.Errorf("unexpected value type of %T", attrValue)
+	}
+
+	stringValuable, diags := t.ValueFromString(ctx, stringValue)
+	if diags.HasError() {
+		return nil, fmt.Errorf("unexpected error converting StringValue to StringValuable: %v", diags)
+	}
+	return stringValuable, nil
+}
@@ -0,0 +1,134 @@
+package frameworktypes
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"context"
+	"fmt"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/attr/xattr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+var (
+	_ basetypes.StringValuableWithSemanticEquals = Policy{}
+	_ xattr.ValidateableAttribute                = Policy{}
+)
+
+// Policy is an attribute value holding an AWS policy document as JSON.
+type Policy struct {
+	basetypes.StringValue
+}
+
+// NewPolicyNull returns a null Policy.
+func NewPolicyNull() Policy {
+	return Policy{StringValue: basetypes.NewStringNull()}
+}
+
+// NewPolicyUnknown returns an unknown Policy.
+func NewPolicyUnknown() Policy {
+	return Policy{StringValue: basetypes.NewStringUnknown()}
+}
+
+// NewPolicyValue returns a known Policy holding the given document.
+func NewPolicyValue(value string) Policy {
+	return Policy{StringValue: basetypes.NewStringValue(value)}
+}
+
+// NewPolicyPointerValue returns a known Policy holding the given document,
+// or a null Policy if value is nil.
+func NewPolicyPointerValue(value *string) Policy {
+	return Policy{StringValue: basetypes.NewStringPointerValue(value)}
+}
+
+// Type returns a PolicyType.
+func (v Policy) Type(ctx context.Context) attr.Type {
+	return PolicyType{}
+}
+
+// Equal returns true if the given value is a Policy holding exactly the
+// same document. Use StringSemanticEquals to compare policies for
+// equivalence.
+func (v Policy) Equal(o attr.Value) bool {
+	other, ok := o.(Policy)
+	if !ok {
+		return false
+	}
+	return v.StringValue.Equal(other.StringValue)
+}
+
+// StringSemanticEquals returns true if the given value holds a policy
+// equivalent to this one, as determined by PoliciesAreEquivalent.
+func (v Policy) StringSemanticEquals(ctx context.Context, newValuable basetypes.StringValuable) (bool, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	newValue, ok := newValuable.(Policy)
+	if !ok {
+		diags.AddError(
+			"Semantic Equality Check Error",
+			"An unexpected value type was received while performing semantic equality checks. "+
+				"Please report this to the provider developers.\n\n"+
+				"Expected Value Type: "+fmt.Sprintf("%T", v)+"\n"+
+				"Got Value Type: "+fmt.Sprintf("%T", newValuable),
+		)
+		return false, diags
+	}
+
+	equivalent, err := awspolicy.PoliciesAreEquivalent(v.ValueString(), newValue.ValueString())
+	if err != nil {
+		diags.AddError(
+			"Semantic Equality Check Error",
+			"An unexpected error occurred while comparing two AWS policy documents. "+
+				"Please report this to the provider developers.\n\n"+
+				"Error: "+err.Error(),
+		)
+		return false, diags
+	}
+	return equivalent, diags
+}
+
+// ValidateAttribute reports an error if a known value is not a valid
+// policy document. The error names the offending element of the policy
+// and its position in the document.
+func (v Policy) ValidateAttribute(ctx context.Context, req xattr.ValidateAttributeRequest, resp *xattr.ValidateAttributeResponse) {
+	if v.IsNull() || v.IsUnknown() {
+		return
+	}
+
+	if _, err := awspolicy.ParsePolicy(v.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid AWS Policy Document",
+			"A string value was provided that is not a valid AWS policy document.\n\n"+
+				"Given Value: "+v.ValueString()+"\n"+
+				"Error: "+err.Error(),
+		)
+	}
+}
+
+// Policy parses the value as a policy. It returns an error diagnostic if
+// the value is null, unknown or not a valid policy document.
+func (v Policy) Policy() (*awspolicy.Policy, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if v.IsNull() {
+		diags.AddError("Policy Parse Error", "AWS policy document string value is null")
+		return nil, diags
+	}
+	if v.IsUnknown() {
+		diags.AddError("Policy Parse Error", "AWS policy document string value is unknown")
+		return nil, diags
+	}
+
+	policy, err := awspolicy.ParsePolicy(v.ValueString())
+	if err != nil {
+		diags.AddError("Policy Parse Error", err.Error())
+		return nil, diags
+	}
+	return policy, diags
+}
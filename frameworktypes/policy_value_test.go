@@ -0,0 +1,164 @@
+package frameworktypes
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr/xattr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+const policy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["s3:GetObject", "s3:ListBucket"],
+      "Resource": "*"
+    }
+  ]
+}`
+
+func TestPolicyStringSemanticEquals(t *testing.T) {
+	cases := []struct {
+		name        string
+		current     Policy
+		given       basetypes.StringValuable
+		equivalent  bool
+		expectError bool
+	}{
+		{
+			name:       "identical",
+			current:    NewPolicyValue(policy),
+			given:      NewPolicyValue(policy),
+			equivalent: true,
+		},
+		{
+			name:       "reordered and collapsed",
+			current:    NewPolicyValue(policy),
+			given:      NewPolicyValue(`{"Statement":{"Resource":["*"],"Action":["s3:ListBucket","s3:GetObject"],"Effect":"Allow"},"Version":"2012-10-17"}`),
+			equivalent: true,
+		},
+		{
+			name:    "different action",
+			current: NewPolicyValue(policy),
+			given:   NewPolicyValue(`{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`),
+		},
+		{
+			name:        "invalid",
+			current:     NewPolicyValue(policy),
+			given:       NewPolicyValue(`{"Statement":`),
+			expectError: true,
+		},
+		{
+			name:        "wrong value type",
+			current:     NewPolicyValue(policy),
+			given:       basetypes.NewStringValue(policy),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		equivalent, diags := tc.current.StringSemanticEquals(context.Background(), tc.given)
+		if diags.HasError() != tc.expectError {
+			t.Fatalf("Bad: %s\n  Expected error: %t\n       Got: %v", tc.name, tc.expectError, diags)
+		}
+		if equivalent != tc.equivalent {
+			t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t", tc.name, tc.equivalent, equivalent)
+		}
+	}
+}
+
+func TestPolicyValidateAttribute(t *testing.T) {
+	cases := []struct {
+		name  string
+		value Policy
+		error string
+	}{
+		{
+			name:  "valid",
+			value: NewPolicyValue(policy),
+		},
+		{
+			name:  "null",
+			value: NewPolicyNull(),
+		},
+		{
+			name:  "unknown",
+			value: NewPolicyUnknown(),
+		},
+		{
+			name:  "syntax error",
+			value: NewPolicyValue("{\n  \"Version\": \"2012-10-17\",\n  \"Statement\": [\n"),
+			error: "unexpected end of JSON input at line 3",
+		},
+		{
+			name:  "bad statement",
+			value: NewPolicyValue("{\n  \"Statement\": [\n    {\"Effect\": \"Allow\"},\n    {\"Effect\": [\"Deny\"]}\n  ]\n}"),
+			error: "Statement[1].Effect:",
+		},
+	}
+
+	for _, tc := range cases {
+		attrPath := path.Root("policy")
+		resp := &xattr.ValidateAttributeResponse{}
+		tc.value.ValidateAttribute(context.Background(), xattr.ValidateAttributeRequest{Path: attrPath}, resp)
+
+		if tc.error == "" {
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("Bad: %s\n  Unexpected diagnostics: %v", tc.name, resp.Diagnostics)
+			}
+			continue
+		}
+
+		if len(resp.Diagnostics) != 1 {
+			t.Fatalf("Bad: %s\n  Expected: one diagnostic\n       Got: %v", tc.name, resp.Diagnostics)
+		}
+		d, ok := resp.Diagnostics[0].(interface{ Path() path.Path })
+		if !ok || !d.Path().Equal(attrPath) {
+			t.Fatalf("Bad: %s\n  Expected: diagnostic for %s\n       Got: %v", tc.name, attrPath, resp.Diagnostics[0])
+		}
+		if detail := resp.Diagnostics[0].Detail(); !strings.Contains(detail, tc.error) {
+			t.Fatalf("Bad: %s\n  Expected: %q in\n       Got: %s", tc.name, tc.error, detail)
+		}
+	}
+}
+
+func TestPolicyTypeValueFromTerraform(t *testing.T) {
+	got, err := PolicyType{}.ValueFromTerraform(context.Background(), tftypes.NewValue(tftypes.String, policy))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := NewPolicyValue(policy); !got.Equal(want) {
+		t.Fatalf("Bad: value from Terraform\n  Expected: %v\n       Got: %v", want, got)
+	}
+
+	got, err = PolicyType{}.ValueFromTerraform(context.Background(), tftypes.NewValue(tftypes.String, tftypes.UnknownValue))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := NewPolicyUnknown(); !got.Equal(want) {
+		t.Fatalf("Bad: value from Terraform\n  Expected: %v\n       Got: %v", want, got)
+	}
+}
+
+func TestPolicyPolicy(t *testing.T) {
+	p, diags := NewPolicyValue(policy).Policy()
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if got := p.Statements()[0].Actions(); len(got) != 2 {
+		t.Fatalf("Bad: actions\n  Expected: 2 actions\n       Got: %v", got)
+	}
+
+	if _, diags := NewPolicyNull().Policy(); !diags.HasError() {
+		t.Fatalf("Bad: null policy\n  Expected: error diagnostic\n       Got: none")
+	}
+}
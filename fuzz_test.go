@@ -0,0 +1,248 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are the pairs compared by TestPolicyEquivalence.
+var fuzzSeeds = [][2]string{
+	{policyTest0, policyTest0},
+	{policyTest1, policyTest1},
+	{policyTest2a, policyTest2b},
+	{policyTest3a, policyTest3b},
+	{policyTest4a, policyTest4b},
+	{policyTest5a, policyTest5b},
+	{policyTest6a, policyTest6b},
+	{policyTest7a, policyTest7b},
+	{policyTest8a, policyTest8b},
+	{policyTest9a, policyTest9b},
+	{policyTest10a, policyTest10b},
+	{policyTest11a, policyTest11b},
+	{policyTest12a, policyTest12b},
+	{policyTest13a, policyTest13b},
+	{policyTest14a, policyTest14b},
+	{policyTest15a, policyTest15b},
+	{policyTest16a, policyTest16b},
+	{policyTest17a, policyTest17b},
+	{policyTest18a, policyTest18b},
+	{policyTest19a, policyTest19b},
+	{policyTest20a, policyTest20b},
+	{policyTest21a, policyTest21b},
+	{policyTest22a, policyTest22b},
+	{policyTest23a, policyTest23b},
+	{policyTest24a, policyTest24b},
+	{policyTest25a, policyTest25b},
+	{policyTest26a, policyTest26b},
+	{policyTest27a, policyTest27b},
+	{policyTest28a, policyTest28b},
+	{policyTest29a, policyTest29b},
+	{policyTest30, policyTest30},
+	{policyTest31, policyTest31},
+	{policyTest32, policyTest32},
+	{policyTest33, policyTest33},
+	{policyTest34a, policyTest34b},
+	{policyTest34b, policyTest34c},
+	{policyTest35a, policyTest35b},
+	{policyTest36a, policyTest36b},
+	{policyTest37a, policyTest37b},
+	{policyTest38a, policyTest38b},
+	{policyTest39a, policyTest39b},
+	{policyTest40a, policyTest40b},
+	{policyTest41a, policyTest41b},
+	{policyTest42a, policyTest42b},
+	{policyTest43a, policyTest43b},
+	{policyTest44a, policyTest44b},
+	{policyTest45a, policyTest45b},
+	{policyTest45a, policyTest45c},
+	{policyTest46a, policyTest46b},
+	{policyTest47a, policyTest47b},
+	{policyTest46a, policyTest47c},
+	{policyTest48a, policyTest48b},
+	{policyTest49a, policyTest49b},
+	{policyTest49a, policyTest49c},
+	{policyTest50a, policyTest50b},
+	{policyTest49a, policyTest50b},
+	{policyTest51a, policyTest51b},
+	{policyTest52a, policyTest52b},
+	{policyTest53a, policyTest53b},
+	{policyTest54a, policyTest54b},
+	{policyTest55a, policyTest55b},
+	{policyTest56a, policyTest56b},
+	{policyTest57a, policyTest57b},
+	{policyTest57a, policyTest57c},
+	{policyTest58a, policyTest58b},
+	{policyTest59a, policyTest59a},
+	{policyTest59a, policyTest59b},
+	{policyTest59a, policyTest59c},
+	{policyTest60a, policyTest60b},
+	{policyTest61a, policyTest61b},
+	{policyTest62a, policyTest62b},
+	{policyTest63a, policyTest63b},
+	{policyTest64a, policyTest64b},
+	{policyTest65a, policyTest65b},
+}
+
+// FuzzPoliciesAreEquivalent checks that PoliciesAreEquivalent never
+// panics and behaves as an equivalence relation: every valid policy is
+// equivalent to itself, the order of the arguments doesn't matter, and a
+// policy equivalent to one of two equivalent policies is equivalent to
+// the other. The third policy is the second written in another shape by
+// reformat, which must be equivalent to it, so that equivalent pairs are
+// common.
+func FuzzPoliciesAreEquivalent(f *testing.F) {
+	for i, seed := range fuzzSeeds {
+		f.Add(seed[0], seed[1], uint8(i))
+	}
+
+	f.Fuzz(func(t *testing.T, policy1, policy2 string, shape uint8) {
+		policy3 := reformat(policy2, shape)
+		if _, err := ParsePolicy(policy2); err == nil {
+			if equivalent, err := PoliciesAreEquivalent(policy2, policy3); !equivalent {
+				t.Fatalf("Bad: reformatted policy\n  %s\n  %s\n  Expected: equivalent\n       Got: %t, %v", policy2, policy3, equivalent, err)
+			}
+		}
+		checkRelation(t, policy1, policy2, policy3)
+	})
+}
+
+// FuzzPrincipalEquivalence checks the same properties for statements
+// built by principalPolicy, which writes the same principals in the
+// forms PoliciesAreEquivalent treats as equivalent.
+func FuzzPrincipalEquivalence(f *testing.F) {
+	f.Add([]byte{0, 1, 2}, []byte{1, 0, 2}, []byte{3, 4})
+	f.Add([]byte{5}, []byte{6}, []byte{5, 6})
+	f.Add([]byte{7, 8}, []byte{8, 7, 9}, []byte{10, 11})
+
+	f.Fuzz(func(t *testing.T, a, b, c []byte) {
+		checkRelation(t, principalPolicy(a), principalPolicy(b), principalPolicy(c))
+	})
+}
+
+// checkRelation checks reflexivity, symmetry and transitivity for three
+// policies. A comparison that fails with an error must fail the same way
+// with its arguments swapped, and counts as not equivalent.
+func checkRelation(t *testing.T, a, b, c string) {
+	t.Helper()
+
+	policies := []string{a, b, c}
+	equivalent := make([][]bool, len(policies))
+	failed := make([][]bool, len(policies))
+	for i, x := range policies {
+		equivalent[i] = make([]bool, len(policies))
+		failed[i] = make([]bool, len(policies))
+		for j, y := range policies {
+			eq, err := PoliciesAreEquivalent(x, y)
+			if err != nil && eq {
+				t.Fatalf("Bad: equivalent with error\n  %s\n  %s\n  Got: %v", x, y, err)
+			}
+			equivalent[i][j], failed[i][j] = eq, err != nil
+		}
+	}
+
+	for i, x := range policies {
+		if !failed[i][i] && !equivalent[i][i] {
+			t.Fatalf("Bad: reflexivity\n  Expected: %s equivalent to itself", x)
+		}
+		for j, y := range policies {
+			if failed[i][j] != failed[j][i] {
+				t.Fatalf("Bad: symmetry of errors\n  %s\n  %s\n  Expected: error both ways or neither", x, y)
+			}
+			if equivalent[i][j] != equivalent[j][i] {
+				t.Fatalf("Bad: symmetry\n  %s\n  %s\n  Expected: %t both ways\n       Got: %t reversed", x, y, equivalent[i][j], equivalent[j][i])
+			}
+			for k, z := range policies {
+				if equivalent[i][j] && equivalent[j][k] && !equivalent[i][k] {
+					t.Fatalf("Bad: transitivity\n  %s\n  %s\n  %s\n  Expected: first equivalent to third", x, y, z)
+				}
+			}
+		}
+	}
+}
+
+// reformat writes policy in one of the shapes Marshal supports, or
+// returns it unchanged if it isn't valid.
+func reformat(policy string, shape uint8) string {
+	p, err := ParsePolicy(policy)
+	if err != nil {
+		return policy
+	}
+
+	opts := [][]MarshalOption{
+		{CanonicalShape()},
+		{PreserveShape()},
+		{ArraysEverywhere()},
+		{CollapseSingleValues()},
+	}[shape%4]
+	if shape&4 != 0 {
+		opts = append(opts, Indent("", "  "))
+	}
+
+	b, err := p.Marshal(opts...)
+	if err != nil {
+		return policy
+	}
+	return string(b)
+}
+
+// principalForms are the ways principalPolicy writes a principal. Forms
+// with the same account or service are equivalent. Root user ARNs are all
+// in the aws partition: an account ID matches a root user ARN in any
+// partition, but two root user ARNs match only within one, so mixing
+// partitions would not be transitive.
+var principalForms = []string{
+	`"AWS": "123456789012"`,
+	`"AWS": "arn:aws:iam::123456789012:root"`,
+	`"AWS": ["123456789012"]`,
+	`"AWS": "*"`,
+	`"AWS": "210987654321"`,
+	`"AWS": "arn:aws:iam::210987654321:role/app"`,
+	`"Service": "ec2.amazonaws.com"`,
+	`"Service": "ec2.amazonaws.com.cn"`,
+	`"Service": "ec2.us-east-1.amazonaws.com"`,
+	`"Service": "ec2.eu-west-1.amazonaws.com"`,
+	`"Service": ["ec2.amazonaws.com", "lambda.amazonaws.com"]`,
+	`"Service": "lambda.amazonaws.com"`,
+	`"Federated": "cognito-identity.amazonaws.com"`,
+	`"CanonicalUser": "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"`,
+	`"Service": []`,
+}
+
+// principalPolicy builds a policy whose Principal is made of the forms
+// picked by data. The first byte picks between a Principal object and a
+// bare "*", and between Principal and NotPrincipal.
+func principalPolicy(data []byte) string {
+	if len(data) == 0 {
+		return `{"Statement":{"Effect":"Allow","Principal":"*","Action":"sts:AssumeRole"}}`
+	}
+
+	key := "Principal"
+	if data[0]&0x80 != 0 {
+		key = "NotPrincipal"
+	}
+	if data[0]&0x40 != 0 {
+		return `{"Statement":{"Effect":"Allow","` + key + `":"*","Action":"sts:AssumeRole"}}`
+	}
+
+	// Later forms of the same type replace earlier ones, as a JSON
+	// decoder would keep the last of duplicate keys.
+	byType := make(map[string]string)
+	var types []string
+	for _, d := range data[1:] {
+		form := principalForms[int(d)%len(principalForms)]
+		principalType := form[:strings.Index(form, ":")]
+		if _, ok := byType[principalType]; !ok {
+			types = append(types, principalType)
+		}
+		byType[principalType] = form
+	}
+	forms := make([]string, len(types))
+	for i, principalType := range types {
+		forms[i] = byType[principalType]
+	}
+	return `{"Statement":{"Effect":"Allow","` + key + `":{` + strings.Join(forms, ",") + `},"Action":"sts:AssumeRole"}}`
+}
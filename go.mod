@@ -1,8 +1,5 @@
 module github.com/hashicorp/awspolicyequivalence
 
-go 1.23
+go 1.23.0
 
-require (
-	github.com/aws/aws-sdk-go-v2 v1.36.1
-	github.com/mitchellh/mapstructure v1.5.0
-)
+require github.com/aws/aws-sdk-go-v2 v1.36.1
@@ -0,0 +1,343 @@
+package hclpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+	"strings"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/awspolicyequivalence/source"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// DefaultVersion is the Version aws_iam_policy_document writes when none
+// is set.
+const DefaultVersion = "2012-10-17"
+
+var documentSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "version"},
+		{Name: "policy_id"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "statement"},
+	},
+}
+
+var statementSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "sid"},
+		{Name: "effect"},
+		{Name: "actions"},
+		{Name: "not_actions"},
+		{Name: "resources"},
+		{Name: "not_resources"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "principals"},
+		{Type: "not_principals"},
+		{Type: "condition"},
+	},
+}
+
+var principalsSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type", Required: true},
+		{Name: "identifiers", Required: true},
+	},
+}
+
+var conditionSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "test", Required: true},
+		{Name: "variable", Required: true},
+		{Name: "values", Required: true},
+	},
+}
+
+// ParsePolicyDocument parses the configuration of an aws_iam_policy_document
+// data source: either a file holding a
+//
+//	data "aws_iam_policy_document" "example" { ... }
+//
+// block, the first of which is used, or the arguments and blocks of one on
+// their own. The policy is built the way the data source builds it:
+// Version defaults to "2012-10-17", Effect to "Allow", a principals block
+// of type "*" with identifiers ["*"] becomes "Principal": "*", and
+// &{...} is written as ${...}. Every value must be static, and the
+// source_policy_documents and override_policy_documents arguments, which
+// refer to other documents, are not supported.
+//
+// Problems with the configuration are reported as a *awspolicy.ParseError
+// with Format set to "HCL" and the position of the offending expression
+// in src. filename is only used in HCL diagnostics.
+func ParsePolicyDocument(src []byte, filename string) (*awspolicy.Policy, error) {
+	p := newParser()
+
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, "", true)
+	}
+
+	body := file.Body.(*hclsyntax.Body)
+	for _, block := range body.Blocks {
+		if block.Type == "data" && len(block.Labels) > 0 && block.Labels[0] == "aws_iam_policy_document" {
+			body = block.Body
+			break
+		}
+	}
+
+	v, err := p.document(body)
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.Policy(v)
+}
+
+// object builds a JSON object value.
+type object struct {
+	*source.Value
+}
+
+func newObject(at source.Pos) object {
+	return object{&source.Value{Pos: at, Kind: source.Object}}
+}
+
+func (o object) set(key string, v *source.Value) {
+	for i, k := range o.Keys {
+		if k == key {
+			o.Elems[i] = v
+			return
+		}
+	}
+	o.Keys = append(o.Keys, key)
+	o.Elems = append(o.Elems, v)
+}
+
+func (o object) get(key string) *source.Value {
+	for i, k := range o.Keys {
+		if k == key {
+			return o.Elems[i]
+		}
+	}
+	return nil
+}
+
+func (p *parser) document(body *hclsyntax.Body) (*source.Value, error) {
+	for _, name := range []string{"source_policy_documents", "override_policy_documents", "source_json", "override_json"} {
+		if attr, ok := body.Attributes[name]; ok {
+			return nil, p.doc.Errorf(pos(attr.SrcRange), "", "%s is not supported", name)
+		}
+	}
+
+	content, diags := body.Content(documentSchema)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, "", false)
+	}
+
+	doc := newObject(pos(body.Range()))
+
+	version := &source.Value{Pos: doc.Pos, Kind: source.String, Text: DefaultVersion}
+	if attr, ok := content.Attributes["version"]; ok {
+		v, err := p.stringValue(attr.Expr, "Version")
+		if err != nil {
+			return nil, err
+		}
+		version = v
+	}
+	doc.set("Version", version)
+
+	if attr, ok := content.Attributes["policy_id"]; ok {
+		v, err := p.stringValue(attr.Expr, "Id")
+		if err != nil {
+			return nil, err
+		}
+		doc.set("Id", v)
+	}
+
+	statements := &source.Value{Pos: doc.Pos, Kind: source.Array}
+	for i, block := range content.Blocks {
+		statement, err := p.statement(block, fmt.Sprintf("Statement[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		statements.Elems = append(statements.Elems, statement)
+	}
+	if len(statements.Elems) > 0 {
+		doc.set("Statement", statements)
+	}
+
+	return doc.Value, nil
+}
+
+func (p *parser) statement(block *hcl.Block, path string) (*source.Value, error) {
+	content, diags := block.Body.Content(statementSchema)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, path, false)
+	}
+
+	statement := newObject(pos(block.DefRange))
+
+	if attr, ok := content.Attributes["sid"]; ok {
+		v, err := p.stringValue(attr.Expr, path+".Sid")
+		if err != nil {
+			return nil, err
+		}
+		if v.Text != "" {
+			statement.set("Sid", v)
+		}
+	}
+
+	effect := &source.Value{Pos: statement.Pos, Kind: source.String, Text: "Allow"}
+	if attr, ok := content.Attributes["effect"]; ok {
+		v, err := p.stringValue(attr.Expr, path+".Effect")
+		if err != nil {
+			return nil, err
+		}
+		effect = v
+	}
+	statement.set("Effect", effect)
+
+	for _, element := range []struct{ attribute, key string }{
+		{"actions", "Action"},
+		{"not_actions", "NotAction"},
+		{"resources", "Resource"},
+		{"not_resources", "NotResource"},
+	} {
+		attr, ok := content.Attributes[element.attribute]
+		if !ok {
+			continue
+		}
+		v, err := p.stringList(attr.Expr, path+"."+element.key)
+		if err != nil {
+			return nil, err
+		}
+		if len(v.Elems) > 0 {
+			statement.set(element.key, collapse(interpolations(v)))
+		}
+	}
+
+	for _, block := range content.Blocks {
+		var err error
+		switch block.Type {
+		case "principals":
+			err = p.principals(statement, "Principal", block, path+".Principal")
+		case "not_principals":
+			err = p.principals(statement, "NotPrincipal", block, path+".NotPrincipal")
+		case "condition":
+			err = p.condition(statement, block, path+".Condition")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return statement.Value, nil
+}
+
+// principals adds a principals or not_principals block to the statement,
+// merging the identifiers of blocks of the same type.
+func (p *parser) principals(statement object, key string, block *hcl.Block, path string) error {
+	content, diags := block.Body.Content(principalsSchema)
+	if diags.HasErrors() {
+		return p.diagError(diags, path, false)
+	}
+
+	principalType, err := p.stringValue(content.Attributes["type"].Expr, path)
+	if err != nil {
+		return err
+	}
+	identifiers, err := p.stringList(content.Attributes["identifiers"].Expr, path+"."+principalType.Text)
+	if err != nil {
+		return err
+	}
+
+	if principalType.Text == "*" && len(identifiers.Elems) == 1 && identifiers.Elems[0].Text == "*" {
+		if statement.get(key) != nil {
+			return p.doc.Errorf(principalType.Pos, path, `type "*" can't be combined with other principals`)
+		}
+		statement.set(key, identifiers.Elems[0])
+		return nil
+	}
+
+	principals := statement.get(key)
+	if principals == nil {
+		principals = newObject(pos(block.DefRange)).Value
+		statement.set(key, principals)
+	} else if principals.Kind != source.Object {
+		return p.doc.Errorf(principalType.Pos, path, `type "*" can't be combined with other principals`)
+	}
+
+	merge(object{principals}, principalType.Text, identifiers)
+	return nil
+}
+
+// condition adds a condition block to the statement, merging the values
+// of blocks with the same test and variable.
+func (p *parser) condition(statement object, block *hcl.Block, path string) error {
+	content, diags := block.Body.Content(conditionSchema)
+	if diags.HasErrors() {
+		return p.diagError(diags, path, false)
+	}
+
+	test, err := p.stringValue(content.Attributes["test"].Expr, path)
+	if err != nil {
+		return err
+	}
+	variable, err := p.stringValue(content.Attributes["variable"].Expr, path+"."+test.Text)
+	if err != nil {
+		return err
+	}
+	values, err := p.stringList(content.Attributes["values"].Expr, path+"."+test.Text+"."+variable.Text)
+	if err != nil {
+		return err
+	}
+
+	conditions := statement.get("Condition")
+	if conditions == nil {
+		conditions = newObject(pos(block.DefRange)).Value
+		statement.set("Condition", conditions)
+	}
+	operator := object{conditions}.get(test.Text)
+	if operator == nil {
+		operator = newObject(test.Pos).Value
+		object{conditions}.set(test.Text, operator)
+	}
+
+	merge(object{operator}, variable.Text, interpolations(values))
+	return nil
+}
+
+// merge adds values to the list at key, collapsing a single value.
+func merge(o object, key string, values *source.Value) {
+	if existing := o.get(key); existing != nil {
+		if existing.Kind == source.Array {
+			values = &source.Value{Pos: existing.Pos, Kind: source.Array, Elems: append(existing.Elems, values.Elems...)}
+		} else {
+			values = &source.Value{Pos: existing.Pos, Kind: source.Array, Elems: append([]*source.Value{existing}, values.Elems...)}
+		}
+	}
+	o.set(key, collapse(values))
+}
+
+// collapse writes a list with a single member as a single value, as the
+// data source does.
+func collapse(v *source.Value) *source.Value {
+	if v.Kind == source.Array && len(v.Elems) == 1 {
+		return v.Elems[0]
+	}
+	return v
+}
+
+// interpolations replaces the &{...} syntax the data source uses for IAM
+// policy variables with ${...}.
+func interpolations(v *source.Value) *source.Value {
+	for _, elem := range v.Elems {
+		elem.Text = strings.ReplaceAll(elem.Text, "&{", "${")
+	}
+	return v
+}
@@ -0,0 +1,134 @@
+package hclpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FormatPolicyDocument converts a policy into the configuration of an
+// aws_iam_policy_document data source with the given name, with one
+// statement block per statement and principals and condition blocks in
+// sorted order. IAM policy variables such as ${aws:username} are written
+// in the data source's &{aws:username} form.
+//
+// The result is checked by parsing it as ParsePolicyDocument does and
+// comparing the JSON the data source would render with the policy using
+// PoliciesAreEquivalent. Policies that the data source can't express, for
+// example ones without a Version, a statement without an Effect or a
+// condition value that is an object, are reported as an error.
+func FormatPolicyDocument(policy *awspolicy.Policy, name string) ([]byte, error) {
+	f := hclwrite.NewEmptyFile()
+	block := f.Body().AppendNewBlock("data", []string{"aws_iam_policy_document", name})
+	body := block.Body()
+
+	if policy.Version() != "" && policy.Version() != DefaultVersion {
+		body.SetAttributeValue("version", cty.StringVal(policy.Version()))
+	}
+	if policy.Id() != "" {
+		body.SetAttributeValue("policy_id", cty.StringVal(policy.Id()))
+	}
+
+	for i, statement := range policy.Statements() {
+		if i > 0 || len(body.Attributes()) > 0 {
+			body.AppendNewline()
+		}
+		formatStatement(body.AppendNewBlock("statement", nil).Body(), statement)
+	}
+
+	src := hclwrite.Format(f.Bytes())
+	if err := check(policy, src); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+func formatStatement(body *hclwrite.Body, statement *awspolicy.Statement) {
+	if statement.Sid() != "" {
+		body.SetAttributeValue("sid", cty.StringVal(statement.Sid()))
+	}
+	if statement.Effect() != "" {
+		body.SetAttributeValue("effect", cty.StringVal(statement.Effect()))
+	}
+
+	setList(body, "actions", statement.Actions())
+	setList(body, "not_actions", statement.NotActions())
+	setList(body, "resources", statement.Resources())
+	setList(body, "not_resources", statement.NotResources())
+
+	formatPrincipals(body, "principals", statement.Principals())
+	formatPrincipals(body, "not_principals", statement.NotPrincipals())
+
+	conditions := statement.Conditions()
+	for _, test := range slices.Sorted(maps.Keys(conditions)) {
+		for _, variable := range slices.Sorted(maps.Keys(conditions[test])) {
+			body.AppendNewline()
+			condition := body.AppendNewBlock("condition", nil).Body()
+			condition.SetAttributeValue("test", cty.StringVal(test))
+			condition.SetAttributeValue("variable", cty.StringVal(variable))
+			setList(condition, "values", conditions[test][variable])
+		}
+	}
+}
+
+func formatPrincipals(body *hclwrite.Body, blockType string, principals map[string][]string) {
+	for _, principalType := range slices.Sorted(maps.Keys(principals)) {
+		identifiers := principals[principalType]
+		if principalType == "AWS" && len(identifiers) == 1 && identifiers[0] == "*" {
+			principalType = "*"
+		}
+
+		body.AppendNewline()
+		block := body.AppendNewBlock(blockType, nil).Body()
+		block.SetAttributeValue("type", cty.StringVal(principalType))
+		setList(block, "identifiers", identifiers)
+	}
+}
+
+func setList(body *hclwrite.Body, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	elems := make([]cty.Value, len(values))
+	for i, v := range values {
+		elems[i] = cty.StringVal(strings.ReplaceAll(v, "${", "&{"))
+	}
+	body.SetAttributeValue(name, cty.ListVal(elems))
+}
+
+// check verifies that the configuration renders a policy equivalent to
+// policy.
+func check(policy *awspolicy.Policy, src []byte) error {
+	rendered, err := ParsePolicyDocument(src, "")
+	if err != nil {
+		return fmt.Errorf("checking aws_iam_policy_document: %w", err)
+	}
+
+	want, err := policy.Marshal()
+	if err != nil {
+		return err
+	}
+	got, err := rendered.Marshal()
+	if err != nil {
+		return err
+	}
+
+	equivalent, err := awspolicy.PoliciesAreEquivalent(string(want), string(got))
+	if err != nil {
+		return fmt.Errorf("checking aws_iam_policy_document: %w", err)
+	}
+	if !equivalent {
+		return fmt.Errorf("policy can't be expressed as an aws_iam_policy_document: it would render as %s", got)
+	}
+	return nil
+}
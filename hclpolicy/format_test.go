@@ -0,0 +1,106 @@
+package hclpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+func TestFormatPolicyDocument(t *testing.T) {
+	policy, err := awspolicy.ParsePolicy(jsonPolicy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	got, err := FormatPolicyDocument(policy, "example")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := `data "aws_iam_policy_document" "example" {
+  policy_id = "example"
+
+  statement {
+    sid       = "Read"
+    effect    = "Allow"
+    actions   = ["s3:GetObject", "s3:ListBucket"]
+    resources = ["arn:aws:s3:::bucket/&{aws:username}/*"]
+
+    principals {
+      type        = "AWS"
+      identifiers = ["arn:aws:iam::123456789012:root", "arn:aws:iam::210987654321:role/app"]
+    }
+
+    principals {
+      type        = "Service"
+      identifiers = ["ec2.amazonaws.com"]
+    }
+
+    condition {
+      test     = "NumericLessThan"
+      variable = "s3:max-keys"
+      values   = ["10"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "aws:SourceVpc"
+      values   = ["vpc-1", "vpc-2"]
+    }
+  }
+
+  statement {
+    effect      = "Deny"
+    not_actions = ["s3:*"]
+    resources   = ["*"]
+
+    principals {
+      type        = "*"
+      identifiers = ["*"]
+    }
+  }
+}
+`
+	if string(got) != want {
+		t.Fatalf("Bad HCL\n  Expected: %s\n       Got: %s", want, got)
+	}
+}
+
+func TestFormatPolicyDocumentErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy string
+	}{
+		{
+			name:   "No Version",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+		},
+		{
+			name:   "No Effect",
+			policy: `{"Version": "2012-10-17", "Statement": [{"Action": "s3:*", "Resource": "*"}]}`,
+		},
+		{
+			name:   "Structured condition value",
+			policy: `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*", "Condition": {"StringEquals": {"custom:Settings": {"tier": "gold"}}}}]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := awspolicy.ParsePolicy(tc.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			_, err = FormatPolicyDocument(policy, "example")
+			if err == nil || !strings.Contains(err.Error(), "can't be expressed as an aws_iam_policy_document") {
+				t.Fatalf("Bad error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package hclpolicy parses AWS policy documents written in HCL, either as
+// static Terraform expressions such as jsonencode({...}) or as the
+// arguments and blocks of an aws_iam_policy_document data source, so that
+// they can be compared with policies written in any other format.
+package hclpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"strconv"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/awspolicyequivalence/source"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Format is the format name reported in a *awspolicy.ParseError.
+const Format = "HCL"
+
+// ParseExpression parses a static HCL expression holding a policy
+// document: an object constructor, optionally wrapped in a call to
+// jsonencode. Expressions that refer to variables, resources or other
+// functions can't be evaluated.
+//
+// Problems with the expression are reported as a *awspolicy.ParseError
+// with Format set to "HCL" and the position of the offending expression
+// in src. filename is only used in HCL diagnostics.
+func ParseExpression(src []byte, filename string) (*awspolicy.Policy, error) {
+	p := newParser()
+
+	expr, diags := hclsyntax.ParseExpression(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, "", true)
+	}
+
+	if call, ok := expr.(*hclsyntax.FunctionCallExpr); ok && call.Name == "jsonencode" {
+		if len(call.Args) != 1 || call.ExpandFinal {
+			return nil, p.doc.Errorf(pos(call.Range()), "", "jsonencode takes a single argument")
+		}
+		expr = call.Args[0]
+	}
+
+	v, err := p.expr(expr, "")
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.Policy(v)
+}
+
+type parser struct {
+	doc *source.Document
+}
+
+func newParser() *parser {
+	return &parser{doc: &source.Document{Format: Format}}
+}
+
+func pos(r hcl.Range) source.Pos {
+	return source.Pos{Offset: int64(r.Start.Byte), Line: r.Start.Line, Column: r.Start.Column}
+}
+
+// diagError converts the first error in diags into a
+// *awspolicy.ParseError.
+func (p *parser) diagError(diags hcl.Diagnostics, path string, syntax bool) error {
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+
+		msg := diag.Summary
+		if diag.Detail != "" {
+			msg += "; " + diag.Detail
+		}
+
+		var at source.Pos
+		if diag.Subject != nil {
+			at = pos(*diag.Subject)
+		}
+		if syntax {
+			return p.doc.SyntaxError(at, errors.New(msg))
+		}
+		return p.doc.Errorf(at, path, "%s", msg)
+	}
+	return nil
+}
+
+// expr converts a static expression into a JSON value. Object and tuple
+// constructors are walked so that their members keep their own positions.
+func (p *parser) expr(expr hclsyntax.Expression, path string) (*source.Value, error) {
+	switch expr := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		v := &source.Value{Pos: pos(expr.Range()), Kind: source.Object}
+		for _, item := range expr.Items {
+			key, err := p.key(item.KeyExpr, path)
+			if err != nil {
+				return nil, err
+			}
+
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			elem, err := p.expr(item.ValueExpr, child)
+			if err != nil {
+				return nil, err
+			}
+			v.Keys = append(v.Keys, key)
+			v.Elems = append(v.Elems, elem)
+		}
+		return v, nil
+	case *hclsyntax.TupleConsExpr:
+		v := &source.Value{Pos: pos(expr.Range()), Kind: source.Array}
+		for i, e := range expr.Exprs {
+			elem, err := p.expr(e, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			v.Elems = append(v.Elems, elem)
+		}
+		return v, nil
+	default:
+		val, diags := expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, p.diagError(diags, path, false)
+		}
+		return p.value(val, pos(expr.Range()), path)
+	}
+}
+
+func (p *parser) key(expr hclsyntax.Expression, path string) (string, error) {
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return "", p.diagError(diags, path, false)
+	}
+
+	key, err := p.value(val, pos(expr.Range()), path)
+	if err != nil {
+		return "", err
+	}
+	if key.Kind != source.String && key.Kind != source.Number && key.Kind != source.Bool {
+		return "", p.doc.Errorf(key.Pos, path, "object keys must be strings")
+	}
+	return key.Text, nil
+}
+
+// value converts an evaluated value into a JSON value at the given
+// position.
+func (p *parser) value(val cty.Value, at source.Pos, path string) (*source.Value, error) {
+	if !val.IsWhollyKnown() {
+		return nil, p.doc.Errorf(at, path, "value must be static")
+	}
+
+	v := &source.Value{Pos: at}
+	if val.IsNull() {
+		v.Kind = source.Null
+		return v, nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		v.Kind, v.Text = source.String, val.AsString()
+	case ty == cty.Number:
+		v.Kind, v.Text = source.Number, val.AsBigFloat().Text('f', -1)
+	case ty == cty.Bool:
+		v.Kind, v.Text = source.Bool, strconv.FormatBool(val.True())
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		v.Kind = source.Array
+		i := 0
+		for it := val.ElementIterator(); it.Next(); i++ {
+			_, ev := it.Element()
+			elem, err := p.value(ev, at, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			v.Elems = append(v.Elems, elem)
+		}
+	case ty.IsMapType() || ty.IsObjectType():
+		v.Kind = source.Object
+		for it := val.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			key := k.AsString()
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			elem, err := p.value(ev, at, child)
+			if err != nil {
+				return nil, err
+			}
+			v.Keys = append(v.Keys, key)
+			v.Elems = append(v.Elems, elem)
+		}
+	default:
+		return nil, p.doc.Errorf(at, path, "unsupported value of type %s", ty.FriendlyName())
+	}
+	return v, nil
+}
+
+// stringValue evaluates an expression that must be a string.
+func (p *parser) stringValue(expr hcl.Expression, path string) (*source.Value, error) {
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, path, false)
+	}
+	v, err := p.value(val, pos(expr.Range()), path)
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind != source.String {
+		return nil, p.doc.Errorf(v.Pos, path, "expected a string, found %s", val.Type().FriendlyName())
+	}
+	return v, nil
+}
+
+// stringList evaluates an expression that must be a list of strings.
+func (p *parser) stringList(expr hcl.Expression, path string) (*source.Value, error) {
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, p.diagError(diags, path, false)
+	}
+	v, err := p.value(val, pos(expr.Range()), path)
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind != source.Array {
+		return nil, p.doc.Errorf(v.Pos, path, "expected a list of strings, found %s", val.Type().FriendlyName())
+	}
+	for _, elem := range v.Elems {
+		if elem.Kind != source.String {
+			return nil, p.doc.Errorf(v.Pos, path, "expected a list of strings, found %s", val.Type().FriendlyName())
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,269 @@
+package hclpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+const jsonPolicy = `{
+  "Version": "2012-10-17",
+  "Id": "example",
+  "Statement": [
+    {
+      "Sid": "Read",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:root", "arn:aws:iam::210987654321:role/app"], "Service": "ec2.amazonaws.com"},
+      "Action": ["s3:GetObject", "s3:ListBucket"],
+      "Resource": "arn:aws:s3:::bucket/${aws:username}/*",
+      "Condition": {"StringEquals": {"aws:SourceVpc": ["vpc-1", "vpc-2"]}, "NumericLessThan": {"s3:max-keys": "10"}}
+    },
+    {
+      "Effect": "Deny",
+      "Principal": "*",
+      "NotAction": "s3:*",
+      "Resource": "*"
+    }
+  ]
+}`
+
+func TestParseExpression(t *testing.T) {
+	cases := []struct {
+		name string
+		hcl  string
+	}{
+		{
+			name: "jsonencode",
+			hcl: `jsonencode({
+  Version = "2012-10-17"
+  Id      = "example"
+  Statement = [
+    {
+      Sid       = "Read"
+      Effect    = "Allow"
+      Principal = { AWS = ["123456789012", "arn:aws:iam::210987654321:role/app"], Service = "ec2.amazonaws.com" }
+      Action    = ["s3:GetObject", "s3:ListBucket"]
+      Resource  = "arn:aws:s3:::bucket/$${aws:username}/*"
+      Condition = {
+        StringEquals    = { "aws:SourceVpc" = ["vpc-2", "vpc-1"] }
+        NumericLessThan = { "s3:max-keys" = 10 }
+      }
+    },
+    {
+      Effect    = "Deny"
+      Principal = "*"
+      NotAction = "s3:*"
+      Resource  = "*"
+    },
+  ]
+})`,
+		},
+		{
+			name: "Object with computed static values",
+			hcl: `{
+  "Version" = "2012-10-17",
+  "Id" = "example",
+  "Statement" = [
+    {"Sid" = "Read", "Effect" = "Allow", "Principal" = {"AWS" = ["123456789012", "arn:aws:iam::210987654321:role/app"], "Service" = ["ec2.amazonaws.com"]}, "Action" = ["s3:ListBucket", "s3:GetObject"], "Resource" = ["arn:aws:s3:::bucket/$${aws:username}/*"], "Condition" = {"StringEquals" = {"aws:SourceVpc" = ["vpc-1", "vpc-2"]}, "NumericLessThan" = {"s3:max-keys" = 5 + 5}}},
+    {"Effect" = "Deny", "Principal" = {"AWS" = "*"}, "NotAction" = ["s3:*"], "Resource" = "*"}
+  ]
+}`,
+		},
+	}
+
+	expected, err := awspolicy.ParsePolicy(jsonPolicy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParseExpression([]byte(tc.hcl), "policy.hcl")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !policy.EquivalentTo(expected) {
+				got, _ := policy.Marshal()
+				t.Fatalf("Bad: %s\n  Expected equivalent to JSON policy, got: %s", tc.name, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicyDocument(t *testing.T) {
+	cases := []struct {
+		name string
+		hcl  string
+	}{
+		{
+			name: "Data source",
+			hcl: `
+data "aws_iam_policy_document" "example" {
+  policy_id = "example"
+
+  statement {
+    sid     = "Read"
+    actions = ["s3:GetObject", "s3:ListBucket"]
+    resources = ["arn:aws:s3:::bucket/&{aws:username}/*"]
+
+    principals {
+      type        = "AWS"
+      identifiers = ["123456789012"]
+    }
+    principals {
+      type        = "AWS"
+      identifiers = ["arn:aws:iam::210987654321:role/app"]
+    }
+    principals {
+      type        = "Service"
+      identifiers = ["ec2.amazonaws.com"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "aws:SourceVpc"
+      values   = ["vpc-1"]
+    }
+    condition {
+      test     = "StringEquals"
+      variable = "aws:SourceVpc"
+      values   = ["vpc-2"]
+    }
+    condition {
+      test     = "NumericLessThan"
+      variable = "s3:max-keys"
+      values   = ["10"]
+    }
+  }
+
+  statement {
+    effect      = "Deny"
+    not_actions = ["s3:*"]
+    resources   = ["*"]
+
+    principals {
+      type        = "*"
+      identifiers = ["*"]
+    }
+  }
+}
+`,
+		},
+	}
+
+	expected, err := awspolicy.ParsePolicy(jsonPolicy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParsePolicyDocument([]byte(tc.hcl), "main.tf")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !policy.EquivalentTo(expected) {
+				got, _ := policy.Marshal()
+				t.Fatalf("Bad: %s\n  Expected equivalent to JSON policy, got: %s", tc.name, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		document bool
+		hcl      string
+		err      string
+		line     int
+		column   int
+		syntax   bool
+	}{
+		{
+			name:   "Invalid expression",
+			hcl:    `{ Version = }`,
+			err:    "HCL: Invalid expression; Expected the start of an expression, but found an invalid expression token. at line 1, column 13",
+			line:   1,
+			column: 13,
+			syntax: true,
+		},
+		{
+			name: "Variable reference",
+			hcl: `jsonencode({
+  Statement = [{ Effect = "Allow", Resource = aws_s3_bucket.example.arn }]
+})`,
+			err:    "HCL: Statement[0].Resource: Variables not allowed; Variables may not be used here. at line 2, column 47",
+			line:   2,
+			column: 47,
+		},
+		{
+			name: "Policy error",
+			hcl: `{
+  Statement = {
+    Condition = { StringEquals = ["a"] }
+  }
+}`,
+			err:    "HCL: Statement.Condition.StringEquals: expected condition operator object, found array at line 3, column 34",
+			line:   3,
+			column: 34,
+		},
+		{
+			name:     "Unsupported argument",
+			document: true,
+			hcl: `statement {
+  action = ["s3:*"]
+}`,
+			err:    `HCL: Statement[0]: Unsupported argument; An argument named "action" is not expected here. Did you mean "actions"? at line 2, column 3`,
+			line:   2,
+			column: 3,
+		},
+		{
+			name:     "Source documents",
+			document: true,
+			hcl:      `source_policy_documents = [data.aws_iam_policy_document.base.json]`,
+			err:      "HCL: source_policy_documents is not supported at line 1, column 1",
+			line:     1,
+			column:   1,
+		},
+		{
+			name:     "Missing principal type",
+			document: true,
+			hcl: `statement {
+  principals {
+    identifiers = ["*"]
+  }
+}`,
+			err:    `HCL: Statement[0].Principal: Missing required argument; The argument "type" is required, but no definition was found. at line 2, column 14`,
+			line:   2,
+			column: 14,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			if tc.document {
+				_, err = ParsePolicyDocument([]byte(tc.hcl), "main.tf")
+			} else {
+				_, err = ParseExpression([]byte(tc.hcl), "policy.hcl")
+			}
+
+			var parseErr *awspolicy.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *awspolicy.ParseError, got %T: %v", err, err)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("Bad error\n  Expected: %s\n       Got: %s", tc.err, err)
+			}
+			if parseErr.Format != Format || parseErr.Line != tc.line || parseErr.Column != tc.column || parseErr.Syntax != tc.syntax {
+				t.Errorf("Bad error details: %+v", parseErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Option configures how PoliciesAreEquivalent compares two policies.
+// An Option holds its own copy of any data it was created with and may be
+// shared between goroutines.
+type Option func(*options)
+
+type options struct {
+	substitutions substitutions
+	urlDecode     bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithAccountIDMapping returns an Option that replaces AWS account IDs
+// before the policies are compared. Keys are the account IDs to replace
+// and values their replacements. The mapping is applied to both policies,
+// to bare account ID principals and to the account ID section of ARNs in
+// principals, Resource, NotResource and ARN-typed condition values.
+func WithAccountIDMapping(mapping map[string]string) Option {
+	mapping = copyMapping(mapping)
+	return func(o *options) {
+		o.substitutions.accountIDs = mergeMapping(o.substitutions.accountIDs, mapping)
+	}
+}
+
+// WithPartitionMapping returns an Option that replaces the partition
+// section of ARNs (for example "aws-us-gov" or "aws-cn" with "aws") before
+// the policies are compared.
+func WithPartitionMapping(mapping map[string]string) Option {
+	mapping = copyMapping(mapping)
+	return func(o *options) {
+		o.substitutions.partitions = mergeMapping(o.substitutions.partitions, mapping)
+	}
+}
+
+// WithRegionMapping returns an Option that replaces the region section of
+// ARNs (for example "us-gov-west-1" with "us-east-1") before the policies
+// are compared.
+func WithRegionMapping(mapping map[string]string) Option {
+	mapping = copyMapping(mapping)
+	return func(o *options) {
+		o.substitutions.regions = mergeMapping(o.substitutions.regions, mapping)
+	}
+}
+
+// WithUniqueIDMapping returns an Option that replaces IAM unique IDs in
+// principals with ARNs before the policies are compared. When a role or
+// user named in a policy is deleted, AWS replaces its ARN with the unique
+// ID of the deleted entity (for example "AROA..." for a role); keys are
+// those unique IDs and values the ARNs they stood for.
+func WithUniqueIDMapping(mapping map[string]string) Option {
+	mapping = copyMapping(mapping)
+	return func(o *options) {
+		o.substitutions.uniqueIDs = mergeMapping(o.substitutions.uniqueIDs, mapping)
+	}
+}
+
+// copyMapping copies a caller supplied mapping so that it can't be altered
+// after the Option has been created.
+func copyMapping(mapping map[string]string) map[string]string {
+	if len(mapping) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(mapping))
+	for k, v := range mapping {
+		c[k] = v
+	}
+	return c
+}
+
+// mergeMapping adds the entries of src to dst, allocating dst if needed.
+func mergeMapping(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,242 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Policy is a parsed AWS policy document.
+//
+// A Policy is not modified by any of its methods other than
+// UnmarshalJSON and may be used by multiple goroutines at once.
+//
+// The zero Policy is an empty document, which is marshaled as {}.
+type Policy struct {
+	doc     *policyDocument
+	marshal marshalOptions
+}
+
+// document returns the parsed document, or an empty one for a nil or
+// zero Policy.
+func (p *Policy) document() *policyDocument {
+	if p == nil || p.doc == nil {
+		return &policyDocument{}
+	}
+	return p.doc
+}
+
+// ParsePolicy parses a policy document. It accepts the same inputs as
+// PoliciesAreEquivalent and applies opts the same way: with
+// WithURLDecoding an encoded document is decoded first, and the mappings
+// of the other options are applied to the parsed policy. Problems with the
+// document are reported as a *ParseError, and problems decoding it as a
+// *DecodeError.
+func ParsePolicy(policy string, opts ...Option) (*Policy, error) {
+	o := newOptions(opts)
+	if o.urlDecode {
+		decoded, err := DecodePolicyDocument(policy)
+		if err != nil {
+			return nil, err
+		}
+		policy = decoded
+	}
+
+	doc, err := parsePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{doc: o.substitutions.apply(doc)}, nil
+}
+
+// Version returns the policy's Version element.
+func (p *Policy) Version() string {
+	return p.document().Version
+}
+
+// Id returns the policy's Id element.
+func (p *Policy) Id() string {
+	return p.document().Id
+}
+
+// Statements returns the policy's statements in document order. A
+// Statement element holding a single statement is returned as a one
+// element slice. null statements are skipped.
+func (p *Policy) Statements() []*Statement {
+	var statements []*Statement
+	for statement := range p.All() {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// All returns an iterator over the policy's statements in document order.
+func (p *Policy) All() iter.Seq[*Statement] {
+	return func(yield func(*Statement) bool) {
+		for _, statement := range p.document().Statements {
+			if statement == nil {
+				continue
+			}
+			if !yield(&Statement{statement: statement, marshal: p.marshal}) {
+				return
+			}
+		}
+	}
+}
+
+// WithStatement returns a copy of the policy with the statement at index i
+// of Statements replaced by s, leaving the other statements untouched so
+// that a policy marshaled with PreserveShape is rewritten only where it
+// changed. It panics if i is out of range.
+func (p *Policy) WithStatement(i int, s *Statement) *Policy {
+	doc := *p.document()
+	doc.Statements = make([]*policyStatement, len(p.document().Statements))
+	copy(doc.Statements, p.document().Statements)
+
+	n := -1
+	for j, statement := range doc.Statements {
+		if statement == nil {
+			continue
+		}
+		if n++; n == i {
+			doc.Statements[j] = s.policyStatement()
+			return &Policy{doc: &doc, marshal: p.marshal}
+		}
+	}
+	panic(fmt.Sprintf("awspolicy: statement index %d out of range with %d statements", i, n+1))
+}
+
+// EquivalentTo reports whether p and other are structurally equivalent,
+// as PoliciesAreEquivalent does.
+func (p *Policy) EquivalentTo(other *Policy, opts ...Option) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+
+	o := newOptions(opts)
+	return o.substitutions.apply(p.document()).equals(o.substitutions.apply(other.document()))
+}
+
+// Statement is a single statement of a parsed policy.
+//
+// Element values are returned the same way whether the document wrote
+// them as a single value or as an array. Duplicates are removed, numbers
+// are written in a canonical form, objects and nested arrays are returned
+// as their JSON text and empty elements are returned as nil.
+//
+// The zero Statement is an empty statement, which is marshaled as {}.
+type Statement struct {
+	statement *policyStatement
+	marshal   marshalOptions
+}
+
+// policyStatement returns the parsed statement, or an empty one for a nil
+// or zero Statement.
+func (s *Statement) policyStatement() *policyStatement {
+	if s == nil || s.statement == nil {
+		return &policyStatement{}
+	}
+	return s.statement
+}
+
+// Sid returns the statement's Sid element.
+func (s *Statement) Sid() string {
+	return s.policyStatement().Sid
+}
+
+// Effect returns the statement's Effect element.
+func (s *Statement) Effect() string {
+	return s.policyStatement().Effect
+}
+
+// Actions returns the statement's Action element.
+func (s *Statement) Actions() []string {
+	return distinct(s.policyStatement().Actions.texts())
+}
+
+// NotActions returns the statement's NotAction element.
+func (s *Statement) NotActions() []string {
+	return distinct(s.policyStatement().NotActions.texts())
+}
+
+// Resources returns the statement's Resource element.
+func (s *Statement) Resources() []string {
+	return distinct(s.policyStatement().Resources.texts())
+}
+
+// NotResources returns the statement's NotResource element.
+func (s *Statement) NotResources() []string {
+	return distinct(s.policyStatement().NotResources.texts())
+}
+
+// Principals returns the statement's Principal element as a map of
+// principal type (such as "AWS" or "Service") to principals. A
+// "Principal": "*" element is returned as {"AWS": ["*"]}.
+func (s *Statement) Principals() map[string][]string {
+	return principalValues(s.policyStatement().Principals)
+}
+
+// NotPrincipals returns the statement's NotPrincipal element in the same
+// form as Principals.
+func (s *Statement) NotPrincipals() map[string][]string {
+	return principalValues(s.policyStatement().NotPrincipals)
+}
+
+// Conditions returns the statement's Condition element as a map of
+// condition operator to condition key to values. Condition keys with no
+// values and operators with no keys are omitted.
+func (s *Statement) Conditions() map[string]map[string][]string {
+	var conditions map[string]map[string][]string
+	for operator, condition := range s.policyStatement().Conditions.normalize() {
+		values := make(map[string][]string, len(condition))
+		for key := range condition {
+			values[key] = distinct(s.policyStatement().Conditions[operator][key].texts())
+		}
+		if conditions == nil {
+			conditions = make(map[string]map[string][]string)
+		}
+		conditions[operator] = values
+	}
+	return conditions
+}
+
+func principalValues(principals *principalsBlock) map[string][]string {
+	if principals == nil {
+		return nil
+	}
+
+	var values map[string][]string
+	for principalType, val := range principals.Types {
+		set := distinct(val.texts())
+		if len(set) == 0 {
+			continue
+		}
+		if values == nil {
+			values = make(map[string][]string)
+		}
+		values[principalType] = set
+	}
+	return values
+}
+
+// distinct returns a copy of set in its original order with duplicates
+// removed, or nil if set is empty.
+func distinct(set []string) []string {
+	if len(set) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(set))
+	values := make([]string, 0, len(set))
+	for _, v := range set {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		values = append(values, v)
+	}
+	return values
+}
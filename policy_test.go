@@ -0,0 +1,95 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(queryPolicyTest1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if policy.Version() != "2012-10-17" || policy.Id() != "query" {
+		t.Fatalf("Bad document: %q, %q", policy.Version(), policy.Id())
+	}
+
+	statements := policy.Statements()
+	if len(statements) != 4 {
+		t.Fatalf("Bad: expected 4 statements, got %d", len(statements))
+	}
+
+	s := statements[0]
+	if s.Sid() != "PassRole" || s.Effect() != "Allow" {
+		t.Errorf("Bad statement: %q, %q", s.Sid(), s.Effect())
+	}
+	if got, want := s.Actions(), []string{"iam:PassRole"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad Actions\n  Expected: %v\n       Got: %v", want, got)
+	}
+	if got, want := s.Resources(), []string{"arn:aws:iam::123456789012:role/app-*", "arn:aws:iam::123456789012:role/ops"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad Resources\n  Expected: %v\n       Got: %v", want, got)
+	}
+	if got, want := s.Conditions(), map[string]map[string][]string{
+		"StringEquals":  {"iam:PassedToService": {"ec2.amazonaws.com"}},
+		"NumericEquals": {"custom:Count": {"1.5"}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad Conditions\n  Expected: %v\n       Got: %v", want, got)
+	}
+	if s.Principals() != nil || s.NotActions() != nil {
+		t.Errorf("Bad: expected no Principal or NotAction, got %v, %v", s.Principals(), s.NotActions())
+	}
+
+	if got, want := statements[2].Principals(), map[string][]string{"AWS": {"*"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad Principals\n  Expected: %v\n       Got: %v", want, got)
+	}
+}
+
+func TestParsePolicyError(t *testing.T) {
+	if _, err := ParsePolicy(policyTest0); err == nil {
+		t.Fatal("Expected error, none produced")
+	}
+}
+
+func TestParsePolicyOptions(t *testing.T) {
+	policy, err := ParsePolicy(url.QueryEscape(queryPolicyTest1), WithURLDecoding(), WithAccountIDMapping(map[string]string{"123456789012": "210987654321"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got, want := policy.Statements()[0].Resources(), []string{"arn:aws:iam::210987654321:role/app-*", "arn:aws:iam::210987654321:role/ops"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bad Resources\n  Expected: %v\n       Got: %v", want, got)
+	}
+
+	var decodeErr *DecodeError
+	if _, err := ParsePolicy("%ZZ", WithURLDecoding()); !errors.As(err, &decodeErr) {
+		t.Fatalf("Bad: undecodable policy\n  Expected: *DecodeError\n       Got: %v", err)
+	}
+}
+
+func TestPolicyEquivalentTo(t *testing.T) {
+	policy1, err := ParsePolicy(policyTest2a)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	policy2, err := ParsePolicy(policyTest2b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	policy3, err := ParsePolicy(policyTest3b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !policy1.EquivalentTo(policy2) {
+		t.Error("Expected policies to be equivalent")
+	}
+	if policy1.EquivalentTo(policy3) {
+		t.Error("Expected policies not to be equivalent")
+	}
+}
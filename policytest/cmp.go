@@ -0,0 +1,40 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// EquateEquivalent returns a cmp.Option that compares strings holding
+// policy documents with PoliciesAreEquivalent, passing opts, so that
+// cmp.Equal and cmp.Diff ignore formatting differences in policies
+// embedded in the values being compared.
+//
+// The option applies to a pair of strings only if both are JSON objects
+// that parse as policies; other strings are compared as usual.
+func EquateEquivalent(opts ...awspolicy.Option) cmp.Option {
+	return cmp.FilterValues(func(x, y string) bool {
+		return isPolicyDocument(x, opts) && isPolicyDocument(y, opts)
+	}, cmp.Comparer(func(x, y string) bool {
+		equivalent, err := awspolicy.PoliciesAreEquivalent(x, y, opts...)
+		return err == nil && equivalent
+	}))
+}
+
+// isPolicyDocument reports whether s holds a policy document, as opposed
+// to any other string. A URL-encoded document starts with a percent sign
+// and is only accepted if opts include WithURLDecoding.
+func isPolicyDocument(s string, opts []awspolicy.Option) bool {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "%") {
+		return false
+	}
+	_, err := awspolicy.ParsePolicy(s, opts...)
+	return err == nil
+}
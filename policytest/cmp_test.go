@@ -0,0 +1,83 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+func TestEquateEquivalent(t *testing.T) {
+	type role struct {
+		Name             string
+		AssumeRolePolicy string
+		Tags             []string
+	}
+
+	cases := []struct {
+		name  string
+		x, y  role
+		opts  []awspolicy.Option
+		equal bool
+	}{
+		{
+			name:  "Equivalent policies",
+			x:     role{Name: "fleet", AssumeRolePolicy: expected},
+			y:     role{Name: "fleet", AssumeRolePolicy: reordered},
+			equal: true,
+		},
+		{
+			name: "Different policies",
+			x:    role{Name: "fleet", AssumeRolePolicy: expected},
+			y:    role{Name: "fleet", AssumeRolePolicy: changed},
+		},
+		{
+			name: "Other strings",
+			x:    role{Name: "fleet", AssumeRolePolicy: expected},
+			y:    role{Name: "Fleet", AssumeRolePolicy: expected},
+		},
+		{
+			name:  "Policies in other fields",
+			x:     role{Tags: []string{`{"Statement":[]}`}},
+			y:     role{Tags: []string{`{ "Statement": [] }`}},
+			equal: true,
+		},
+		{
+			name: "Invalid policy",
+			x:    role{AssumeRolePolicy: `{"Statement":`},
+			y:    role{AssumeRolePolicy: `{"Statement": `},
+		},
+		{
+			name: "Not a document",
+			x:    role{AssumeRolePolicy: `null`},
+			y:    role{AssumeRolePolicy: ``},
+		},
+		{
+			name: "URL-encoded without option",
+			x:    role{AssumeRolePolicy: expected},
+			y:    role{AssumeRolePolicy: url.QueryEscape(reordered)},
+		},
+		{
+			name:  "URL-encoded",
+			x:     role{AssumeRolePolicy: expected},
+			y:     role{AssumeRolePolicy: url.QueryEscape(reordered)},
+			opts:  []awspolicy.Option{awspolicy.WithURLDecoding()},
+			equal: true,
+		},
+	}
+
+	for _, tc := range cases {
+		opt := EquateEquivalent(tc.opts...)
+		if equal := cmp.Equal(tc.x, tc.y, opt); equal != tc.equal {
+			t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t\n%s", tc.name, tc.equal, equal, cmp.Diff(tc.x, tc.y, opt))
+		}
+		if equal := cmp.Equal(tc.y, tc.x, opt); equal != tc.equal {
+			t.Fatalf("Bad: %s (reversed)\n  Expected: %t\n       Got: %t", tc.name, tc.equal, equal)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// maxAttempts bounds how many random variants are generated before
+// falling back to a simpler one.
+const maxAttempts = 10
+
+// Generator produces random variants of policy documents, for testing
+// code that handles policies against the ways AWS rewrites them. The
+// variants of a policy depend only on the seed, so failures can be
+// reproduced; objects are always visited in sorted key order for this
+// reason.
+//
+// A Generator is not safe for concurrent use.
+type Generator struct {
+	rand *rand.Rand
+}
+
+// NewGenerator returns a Generator seeded with seed.
+func NewGenerator(seed uint64) *Generator {
+	return &Generator{rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Equivalent returns a random variant of policy that PoliciesAreEquivalent
+// considers equivalent to it. Any of the following may be applied:
+// statements, lists and object keys are shuffled, values are duplicated,
+// single values are wrapped in arrays or unwrapped, booleans and numbers
+// in conditions are quoted or unquoted, account IDs in principals are
+// swapped for root ARNs and back, "*" principals are written as
+// {"AWS": "*"}, empty principal types and Sids are added, and the
+// whitespace between tokens is changed.
+//
+// Every variant is checked with PoliciesAreEquivalent before it is
+// returned.
+func (g *Generator) Equivalent(policy string) (string, error) {
+	doc, err := decode(policy)
+	if err != nil {
+		return "", err
+	}
+
+	for range maxAttempts {
+		variant := g.encode(g.rewrite(clone(doc)))
+		if equivalent, _ := awspolicy.PoliciesAreEquivalent(policy, variant); equivalent {
+			return variant, nil
+		}
+	}
+
+	// Reformatting alone is always equivalent.
+	return g.encode(doc), nil
+}
+
+// Mutate returns a random near miss of policy: a variant that differs
+// from it in a single small way, such as a flipped Effect, a dropped or
+// altered value, an Action written as NotAction or a changed condition
+// operator, disguised with the rewrites Equivalent applies. The variant
+// is checked to be not equivalent to policy with PoliciesAreEquivalent,
+// and an error is returned if no such variant is found.
+func (g *Generator) Mutate(policy string) (string, error) {
+	doc, err := decode(policy)
+	if err != nil {
+		return "", err
+	}
+
+	for range maxAttempts {
+		mutant := clone(doc)
+		if !g.mutate(mutant) {
+			continue
+		}
+		variant := g.encode(g.rewrite(mutant))
+		if equivalent, err := awspolicy.PoliciesAreEquivalent(policy, variant); err == nil && !equivalent {
+			return variant, nil
+		}
+	}
+
+	// A policy with nothing to mutate gains a statement.
+	mutant := clone(doc)
+	mutant["Statement"] = append(list(mutant["Statement"]), map[string]interface{}{
+		"Effect":   "Deny",
+		"Action":   "*",
+		"Resource": "*",
+	})
+	variant := g.encode(mutant)
+	if equivalent, _ := awspolicy.PoliciesAreEquivalent(policy, variant); equivalent {
+		return "", errors.New("policy can't be mutated")
+	}
+	return variant, nil
+}
+
+// decode reads a policy as generic JSON, keeping numbers as written.
+func decode(policy string) (map[string]interface{}, error) {
+	if _, err := awspolicy.ParsePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(policy))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("policy document is null")
+	}
+	return doc, nil
+}
+
+func clone(doc map[string]interface{}) map[string]interface{} {
+	var deep func(v interface{}) interface{}
+	deep = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for k, e := range v {
+				m[k] = deep(e)
+			}
+			return m
+		case []interface{}:
+			l := make([]interface{}, len(v))
+			for i, e := range v {
+				l[i] = deep(e)
+			}
+			return l
+		}
+		return v
+	}
+	return deep(doc).(map[string]interface{})
+}
+
+// list returns a value written as a single value or an array as a list.
+func list(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{v}
+}
+
+func (g *Generator) coin() bool {
+	return g.rand.IntN(2) == 0
+}
+
+// shape shuffles a list, sometimes duplicating a member, and writes a
+// single member as a single value or an array.
+func (g *Generator) shape(values []interface{}) interface{} {
+	g.rand.Shuffle(len(values), func(i, j int) {
+		values[i], values[j] = values[j], values[i]
+	})
+	if len(values) > 0 && g.rand.IntN(4) == 0 {
+		values = append(values, values[g.rand.IntN(len(values))])
+	}
+	if len(values) == 1 && g.coin() {
+		return values[0]
+	}
+	return values
+}
+
+// rewrite applies random equivalent rewrites to a document.
+func (g *Generator) rewrite(doc map[string]interface{}) map[string]interface{} {
+	partition := documentPartition(doc)
+
+	statements := list(doc["Statement"])
+	for _, s := range statements {
+		if statement, ok := s.(map[string]interface{}); ok {
+			g.rewriteStatement(statement, partition)
+		}
+	}
+	g.rand.Shuffle(len(statements), func(i, j int) {
+		statements[i], statements[j] = statements[j], statements[i]
+	})
+	if len(statements) == 1 && g.coin() {
+		doc["Statement"] = statements[0]
+	} else if statements != nil {
+		doc["Statement"] = statements
+	}
+	return doc
+}
+
+func (g *Generator) rewriteStatement(statement map[string]interface{}, partition string) {
+	for _, key := range []string{"Action", "NotAction", "Resource", "NotResource"} {
+		if v, ok := statement[key]; ok {
+			statement[key] = g.shape(list(v))
+		}
+	}
+
+	for _, key := range []string{"Principal", "NotPrincipal"} {
+		if v, ok := statement[key]; ok {
+			statement[key] = g.rewritePrincipals(v, partition)
+		}
+	}
+
+	if conditions, ok := statement["Condition"].(map[string]interface{}); ok {
+		for _, operator := range slices.Sorted(maps.Keys(conditions)) {
+			keys, ok := conditions[operator].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, key := range slices.Sorted(maps.Keys(keys)) {
+				values := list(keys[key])
+				for i, value := range values {
+					values[i] = g.requote(value)
+				}
+				keys[key] = g.shape(values)
+			}
+		}
+	}
+
+	if _, ok := statement["Sid"]; !ok && g.rand.IntN(4) == 0 {
+		statement["Sid"] = ""
+	}
+}
+
+var principalTypes = []string{"AWS", "Service", "Federated", "CanonicalUser"}
+
+func (g *Generator) rewritePrincipals(v interface{}, partition string) interface{} {
+	if v == "*" {
+		if g.coin() {
+			return map[string]interface{}{"AWS": "*"}
+		}
+		return v
+	}
+
+	principals, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for _, principalType := range slices.Sorted(maps.Keys(principals)) {
+		values := list(principals[principalType])
+		if principalType == "AWS" {
+			for i, value := range values {
+				if s, ok := value.(string); ok && g.coin() {
+					values[i] = swapRoot(s, partition)
+				}
+			}
+		}
+		principals[principalType] = g.shape(values)
+	}
+
+	if g.rand.IntN(4) == 0 {
+		principalType := principalTypes[g.rand.IntN(len(principalTypes))]
+		if _, ok := principals[principalType]; !ok {
+			principals[principalType] = []interface{}{}
+		}
+	}
+	return principals
+}
+
+var (
+	accountID = regexp.MustCompile(`^\d{12}$`)
+	rootARN   = regexp.MustCompile(`^arn:[\w-]+:iam::(\d{12}):root$`)
+	arn       = regexp.MustCompile(`"arn:([\w-]+):`)
+	number    = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?$`)
+)
+
+// swapRoot writes an account ID as the ARN of the account's root user,
+// and the other way around.
+func swapRoot(s, partition string) string {
+	if accountID.MatchString(s) {
+		return "arn:" + partition + ":iam::" + s + ":root"
+	}
+	if m := rootARN.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return s
+}
+
+// documentPartition returns the partition of the first ARN in the
+// document, so that root ARNs are written in the same partition.
+func documentPartition(doc map[string]interface{}) string {
+	b, _ := json.Marshal(doc)
+	if m := arn.FindSubmatch(b); m != nil {
+		return string(m[1])
+	}
+	return "aws"
+}
+
+// requote writes a boolean or number condition value as a string, or a
+// string holding one as a boolean or number.
+func (g *Generator) requote(value interface{}) interface{} {
+	if !g.coin() {
+		return value
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		switch {
+		case v == "true":
+			return true
+		case v == "false":
+			return false
+		case number.MatchString(v):
+			return json.Number(v)
+		}
+	}
+	return value
+}
+
+// mutate applies a random non-equivalent change to a document. It
+// returns false if the chosen change could not be applied.
+func (g *Generator) mutate(doc map[string]interface{}) bool {
+	statements := list(doc["Statement"])
+	if len(statements) == 0 {
+		return false
+	}
+	i := g.rand.IntN(len(statements))
+	statement, ok := statements[i].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	switch g.rand.IntN(6) {
+	case 0:
+		if statement["Effect"] == "Allow" {
+			statement["Effect"] = "Deny"
+		} else {
+			statement["Effect"] = "Allow"
+		}
+	case 1:
+		key := g.element(statement)
+		values := list(statement[key])
+		if len(values) < 2 {
+			return false
+		}
+		j := g.rand.IntN(len(values))
+		statement[key] = append(values[:j:j], values[j+1:]...)
+	case 2:
+		key := g.element(statement)
+		values := list(statement[key])
+		if len(values) == 0 {
+			return false
+		}
+		j := g.rand.IntN(len(values))
+		s, ok := values[j].(string)
+		if !ok {
+			return false
+		}
+		values[j] = alter(s)
+		statement[key] = values
+	case 3:
+		key := g.element(statement)
+		negated := map[string]string{
+			"Action": "NotAction", "NotAction": "Action",
+			"Resource": "NotResource", "NotResource": "Resource",
+		}[key]
+		if negated == "" || statement[negated] != nil {
+			return false
+		}
+		statement[negated] = statement[key]
+		delete(statement, key)
+	case 4:
+		conditions, ok := statement["Condition"].(map[string]interface{})
+		if !ok || len(conditions) == 0 {
+			return false
+		}
+		operators := slices.Sorted(maps.Keys(conditions))
+		operator := operators[g.rand.IntN(len(operators))]
+		changed := "StringNotEquals"
+		if operator == changed {
+			changed = "StringEquals"
+		}
+		if _, ok := conditions[changed]; ok {
+			return false
+		}
+		conditions[changed] = conditions[operator]
+		delete(conditions, operator)
+	case 5:
+		if len(statements) < 2 {
+			return false
+		}
+		doc["Statement"] = append(statements[:i:i], statements[i+1:]...)
+	}
+	return true
+}
+
+// element picks one of the list elements a statement has.
+func (g *Generator) element(statement map[string]interface{}) string {
+	var keys []string
+	for _, key := range []string{"Action", "NotAction", "Resource", "NotResource"} {
+		if _, ok := statement[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[g.rand.IntN(len(keys))]
+}
+
+// alter changes a value slightly, keeping wildcards meaningful.
+func alter(s string) string {
+	if s == "*" {
+		return "s3:*"
+	}
+	return s + "x"
+}
+
+var whitespace = []string{"", "", " ", "\n", "\t", "\n  ", "\r\n"}
+
+// encode writes a document as JSON with object keys in random order and
+// random whitespace between tokens.
+func (g *Generator) encode(doc map[string]interface{}) string {
+	var b bytes.Buffer
+	g.value(&b, doc)
+	b.WriteString(g.space())
+	return b.String()
+}
+
+func (g *Generator) space() string {
+	return whitespace[g.rand.IntN(len(whitespace))]
+}
+
+func (g *Generator) value(b *bytes.Buffer, v interface{}) {
+	b.WriteString(g.space())
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := slices.Sorted(maps.Keys(v))
+		g.rand.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(g.space())
+			g.scalar(b, k)
+			b.WriteString(g.space())
+			b.WriteByte(':')
+			g.value(b, v[k])
+		}
+		b.WriteString(g.space())
+		b.WriteByte('}')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			g.value(b, e)
+		}
+		b.WriteString(g.space())
+		b.WriteByte(']')
+	default:
+		g.scalar(b, v)
+	}
+}
+
+func (g *Generator) scalar(b *bytes.Buffer, v interface{}) {
+	// Marshaling strings, numbers, booleans and null can't fail.
+	s, _ := json.Marshal(v)
+	b.Write(s)
+}
@@ -0,0 +1,107 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+var generatorPolicies = []struct {
+	name   string
+	policy string
+}{
+	{
+		name:   "statements",
+		policy: expected,
+	},
+	{
+		name: "principals and conditions",
+		policy: `{
+  "Version": "2012-10-17",
+  "Id": "queue-policy",
+  "Statement": {
+    "Sid": "Send",
+    "Effect": "Allow",
+    "Principal": {"AWS": ["123456789012", "arn:aws-cn:iam::210987654321:root"], "Service": "sns.amazonaws.com"},
+    "Action": ["sqs:SendMessage", "sqs:GetQueueUrl"],
+    "Resource": "arn:aws-cn:sqs:cn-north-1:123456789012:queue",
+    "Condition": {
+      "Bool": {"aws:SecureTransport": true},
+      "NumericLessThanEquals": {"sqs:MaxMessageSize": [1024, "2048"]},
+      "ArnEquals": {"aws:SourceArn": ["arn:aws-cn:sns:cn-north-1:123456789012:a", "arn:aws-cn:sns:cn-north-1:123456789012:b"]}
+    }
+  }
+}`,
+	},
+	{
+		name:   "wildcard principal",
+		policy: `{"Statement":[{"Effect":"Deny","Principal":"*","NotAction":"s3:GetObject","NotResource":["arn:aws:s3:::a/*","arn:aws:s3:::b/*"]}]}`,
+	},
+	{
+		name:   "empty",
+		policy: `{}`,
+	},
+}
+
+func TestGeneratorEquivalent(t *testing.T) {
+	for _, tc := range generatorPolicies {
+		g := NewGenerator(1)
+		distinct := make(map[string]bool)
+		for i := 0; i < 200; i++ {
+			variant, err := g.Equivalent(tc.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			equivalent, err := awspolicy.PoliciesAreEquivalent(tc.policy, variant)
+			if err != nil || !equivalent {
+				t.Fatalf("Bad: %s variant %d\n  Expected: equivalent to %s\n       Got: %s (%v)", tc.name, i, tc.policy, variant, err)
+			}
+			distinct[variant] = true
+		}
+		if len(distinct) < 100 {
+			t.Fatalf("Bad: %s\n  Expected: at least 100 distinct variants\n       Got: %d", tc.name, len(distinct))
+		}
+	}
+}
+
+func TestGeneratorMutate(t *testing.T) {
+	for _, tc := range generatorPolicies {
+		g := NewGenerator(2)
+		for i := 0; i < 200; i++ {
+			mutant, err := g.Mutate(tc.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			equivalent, err := awspolicy.PoliciesAreEquivalent(tc.policy, mutant)
+			if err != nil || equivalent {
+				t.Fatalf("Bad: %s mutant %d\n  Expected: not equivalent to %s\n       Got: %s (%v)", tc.name, i, tc.policy, mutant, err)
+			}
+		}
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	for _, tc := range generatorPolicies {
+		g1, g2 := NewGenerator(3), NewGenerator(3)
+		for i := 0; i < 20; i++ {
+			x, _ := g1.Equivalent(tc.policy)
+			y, _ := g2.Equivalent(tc.policy)
+			if x != y {
+				t.Fatalf("Bad: %s variant %d\n  Expected: same variant for same seed\n       Got: %s and %s", tc.name, i, x, y)
+			}
+			x, _ = g1.Mutate(tc.policy)
+			y, _ = g2.Mutate(tc.policy)
+			if x != y {
+				t.Fatalf("Bad: %s mutant %d\n  Expected: same mutant for same seed\n       Got: %s and %s", tc.name, i, x, y)
+			}
+		}
+	}
+
+	if _, err := NewGenerator(3).Equivalent(`{"Statement":`); err == nil {
+		t.Fatalf("Bad: invalid policy\n  Expected: error\n       Got: none")
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/hashicorp/awspolicyequivalence/policytest
+
+go 1.23.0
+
+require (
+	github.com/google/go-cmp v0.7.0
+	github.com/hashicorp/awspolicyequivalence v1.8.0
+)
+
+require github.com/aws/aws-sdk-go-v2 v1.36.1 // indirect
+
+// Ignored outside this repository. See "Nested modules" in README.md.
+replace github.com/hashicorp/awspolicyequivalence => ../
@@ -0,0 +1,60 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// PolicyMatcher matches policies equivalent to an expected policy. It
+// implements Gomega's GomegaMatcher interface.
+type PolicyMatcher struct {
+	expected string
+	opts     []awspolicy.Option
+
+	diff string
+}
+
+// BeEquivalentPolicy returns a matcher that succeeds if the actual
+// value, a string or []byte, holds a policy equivalent to expected under
+// PoliciesAreEquivalent with opts.
+func BeEquivalentPolicy(expected string, opts ...awspolicy.Option) *PolicyMatcher {
+	return &PolicyMatcher{expected: expected, opts: opts}
+}
+
+// Match reports whether actual holds an equivalent policy. It returns an
+// error if actual is not a string or []byte or either policy is invalid.
+func (m *PolicyMatcher) Match(actual interface{}) (bool, error) {
+	var document string
+	switch actual := actual.(type) {
+	case string:
+		document = actual
+	case []byte:
+		document = string(actual)
+	default:
+		return false, fmt.Errorf("BeEquivalentPolicy expects a string or []byte, got %T", actual)
+	}
+
+	diff, err := Diff(m.expected, document, m.opts...)
+	if err != nil {
+		return false, err
+	}
+	m.diff = diff
+	return diff == "", nil
+}
+
+// FailureMessage describes the difference found by the last call to
+// Match.
+func (m *PolicyMatcher) FailureMessage(actual interface{}) string {
+	return "Expected policies to be equivalent\nDiff (-expected +actual):\n" + m.diff
+}
+
+// NegatedFailureMessage describes a policy that was unexpectedly
+// equivalent.
+func (m *PolicyMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n\t%s\nnot to be equivalent to\n\t%s", actual, m.expected)
+}
@@ -0,0 +1,183 @@
+// Package policytest provides test assertions and matchers for policy
+// documents, built on PoliciesAreEquivalent. Failures show the difference
+// between the policies statement by statement, with the formatting of
+// each document normalized.
+//
+// The assertions follow the conventions of testify's assert package and
+// the matcher implements Gomega's GomegaMatcher interface, without this
+// package depending on either:
+//
+//	policytest.AssertEquivalent(t, expected, role.AssumeRolePolicy)
+//	Expect(role.AssumeRolePolicy).To(policytest.BeEquivalentPolicy(expected))
+//
+// EquateEquivalent makes cmp.Equal and cmp.Diff compare policies embedded
+// in other values by equivalence. A Generator produces random variants of
+// a policy, equivalent or not, for testing code that handles policies
+// returned by AWS.
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// TestingT is the interface of *testing.T used by the assertions. It is
+// the same as testify's assert.TestingT.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// RequireT is the interface of *testing.T used by the Require
+// assertions. It is the same as testify's require.TestingT.
+type RequireT interface {
+	TestingT
+	FailNow()
+}
+
+type tHelper interface {
+	Helper()
+}
+
+// Diff returns the difference between two policies, or an empty string
+// if they are equivalent under PoliciesAreEquivalent with opts. The
+// difference is reported as by cmp.Diff, with lines prefixed by - for
+// expected and + for actual, between the elements of the policies
+// written in their canonical form with statements in sorted order. The
+// policies are decoded and have their mappings applied as opts direct
+// before they are written.
+func Diff(expected, actual string, opts ...awspolicy.Option) (string, error) {
+	equivalent, err := awspolicy.PoliciesAreEquivalent(expected, actual, opts...)
+	if err != nil {
+		return "", err
+	}
+	if equivalent {
+		return "", nil
+	}
+
+	x, err := lines(expected, opts)
+	if err != nil {
+		return "", err
+	}
+	y, err := lines(actual, opts)
+	if err != nil {
+		return "", err
+	}
+	diff := cmp.Diff(x, y)
+	if diff == "" {
+		// The policies differ only in ways the canonical form does not
+		// show.
+		diff = fmt.Sprintf("-\t%s\n+\t%s\n", expected, actual)
+	}
+	return diff, nil
+}
+
+// lines returns the elements of a policy as lines: Version, Id and each
+// statement, canonically indented, in sorted order so that statements
+// that were only reordered show no difference.
+func lines(document string, opts []awspolicy.Option) ([]string, error) {
+	policy, err := awspolicy.ParsePolicy(document, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, s := range policy.Statements() {
+		b, err := s.Marshal(awspolicy.Indent("", "  "))
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, string(b))
+	}
+	sort.Strings(statements)
+
+	result := []string{"Version: " + policy.Version(), "Id: " + policy.Id()}
+	for _, s := range statements {
+		result = append(result, strings.Split(s, "\n")...)
+	}
+	return result, nil
+}
+
+// AssertEquivalent asserts that two policies are equivalent, reporting
+// the difference between them if they are not. It returns whether the
+// assertion succeeded.
+func AssertEquivalent(t TestingT, expected, actual string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	diff, err := Diff(expected, actual)
+	switch {
+	case err != nil:
+		t.Errorf("%s", failure("Invalid policy: "+err.Error(), msgAndArgs))
+		return false
+	case diff != "":
+		t.Errorf("%s", failure("Policies are not equivalent\nDiff (-expected +actual):\n"+diff, msgAndArgs))
+		return false
+	}
+	return true
+}
+
+// AssertNotEquivalent asserts that two valid policies are not
+// equivalent. It returns whether the assertion succeeded.
+func AssertNotEquivalent(t TestingT, expected, actual string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	equivalent, err := awspolicy.PoliciesAreEquivalent(expected, actual)
+	switch {
+	case err != nil:
+		t.Errorf("%s", failure("Invalid policy: "+err.Error(), msgAndArgs))
+		return false
+	case equivalent:
+		t.Errorf("%s", failure("Policies should not be equivalent\n  actual: "+actual, msgAndArgs))
+		return false
+	}
+	return true
+}
+
+// RequireEquivalent is like AssertEquivalent, but stops the test if the
+// assertion fails.
+func RequireEquivalent(t RequireT, expected, actual string, msgAndArgs ...interface{}) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+	if !AssertEquivalent(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// RequireNotEquivalent is like AssertNotEquivalent, but stops the test
+// if the assertion fails.
+func RequireNotEquivalent(t RequireT, expected, actual string, msgAndArgs ...interface{}) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+	if !AssertNotEquivalent(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// failure appends the optional message of an assertion, given as a
+// format string and its arguments or as a single value, to msg.
+func failure(msg string, msgAndArgs []interface{}) string {
+	if len(msgAndArgs) == 0 {
+		return msg
+	}
+
+	var m string
+	if format, ok := msgAndArgs[0].(string); ok && len(msgAndArgs) > 1 {
+		m = fmt.Sprintf(format, msgAndArgs[1:]...)
+	} else {
+		m = fmt.Sprint(msgAndArgs[0])
+	}
+	return msg + "\nMessages: " + m
+}
@@ -0,0 +1,194 @@
+package policytest
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+const (
+	expected = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "Read", "Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "*"},
+    {"Sid": "Deny", "Effect": "Deny", "Action": "s3:DeleteObject", "Resource": "*"}
+  ]
+}`
+
+	reordered = `{"Statement":[{"Sid":"Deny","Effect":"Deny","Action":["s3:DeleteObject"],"Resource":["*"]},{"Sid":"Read","Effect":"Allow","Action":["s3:ListBucket","s3:GetObject"],"Resource":"*"}],"Version":"2012-10-17"}`
+
+	changed = `{"Statement":[{"Sid":"Deny","Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"},{"Sid":"Read","Effect":"Allow","Action":"s3:GetObject","Resource":"*"}],"Version":"2012-10-17"}`
+)
+
+// recorder records the failures reported by an assertion.
+type recorder struct {
+	errors []string
+	failed bool
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) FailNow() {
+	r.failed = true
+}
+
+func TestDiff(t *testing.T) {
+	diff, err := Diff(expected, reordered)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if diff != "" {
+		t.Fatalf("Bad: equivalent policies\n  Expected: no difference\n       Got: %s", diff)
+	}
+
+	diff, err = Diff(expected, changed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, want := range []string{`-`, `"s3:ListBucket"`, `+`, `"Action": "s3:GetObject"`} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("Bad: difference\n  Expected: %q in\n       Got: %s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "DeleteObject") {
+		t.Fatalf("Bad: difference\n  Expected: unchanged statement left out\n       Got: %s", diff)
+	}
+
+	if _, err := Diff(expected, `{"Statement":`); err == nil {
+		t.Fatalf("Bad: invalid policy\n  Expected: error\n       Got: none")
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	decode := awspolicy.WithURLDecoding()
+
+	diff, err := Diff(expected, url.QueryEscape(reordered), decode)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if diff != "" {
+		t.Fatalf("Bad: equivalent encoded policy\n  Expected: no difference\n       Got: %s", diff)
+	}
+
+	diff, err = Diff(expected, url.QueryEscape(changed), decode)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(diff, `"s3:ListBucket"`) || strings.Contains(diff, "%") {
+		t.Fatalf("Bad: difference of encoded policy\n  Expected: decoded statements\n       Got: %s", diff)
+	}
+
+	govCloud := `{"Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws-us-gov:s3:::bucket/*"}}`
+	commercial := `{"Statement":{"Effect":"Allow","Action":"s3:PutObject","Resource":"arn:aws:s3:::bucket/*"}}`
+	diff, err = Diff(govCloud, commercial, awspolicy.WithPartitionMapping(map[string]string{"aws-us-gov": "aws"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(diff, "s3:PutObject") || strings.Contains(diff, "aws-us-gov") {
+		t.Fatalf("Bad: difference with mapping\n  Expected: only the action to differ\n       Got: %s", diff)
+	}
+
+	m := BeEquivalentPolicy(expected, decode)
+	ok, err := m.Match(url.QueryEscape(reordered))
+	if err != nil || !ok {
+		t.Fatalf("Bad: equivalent encoded policy\n  Expected: match\n       Got: %t, %v", ok, err)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	cases := []struct {
+		name   string
+		assert func(r *recorder) bool
+		ok     bool
+		error  string
+	}{
+		{
+			name:   "AssertEquivalent",
+			assert: func(r *recorder) bool { return AssertEquivalent(r, expected, reordered) },
+			ok:     true,
+		},
+		{
+			name:   "AssertEquivalent with different policies",
+			assert: func(r *recorder) bool { return AssertEquivalent(r, expected, changed, "role %s", "app") },
+			error:  "Policies are not equivalent\nDiff (-expected +actual):\n",
+		},
+		{
+			name:   "AssertEquivalent with invalid policy",
+			assert: func(r *recorder) bool { return AssertEquivalent(r, expected, "{") },
+			error:  "Invalid policy: ",
+		},
+		{
+			name:   "AssertNotEquivalent",
+			assert: func(r *recorder) bool { return AssertNotEquivalent(r, expected, changed) },
+			ok:     true,
+		},
+		{
+			name:   "AssertNotEquivalent with equivalent policies",
+			assert: func(r *recorder) bool { return AssertNotEquivalent(r, expected, reordered) },
+			error:  "Policies should not be equivalent",
+		},
+		{
+			name: "RequireEquivalent",
+			assert: func(r *recorder) bool {
+				RequireEquivalent(r, expected, changed)
+				return !r.failed
+			},
+			error: "Policies are not equivalent",
+		},
+	}
+
+	for _, tc := range cases {
+		r := &recorder{}
+		if ok := tc.assert(r); ok != tc.ok {
+			t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t", tc.name, tc.ok, ok)
+		}
+		if tc.ok {
+			if len(r.errors) > 0 {
+				t.Fatalf("Bad: %s\n  Unexpected failure: %s", tc.name, r.errors[0])
+			}
+			continue
+		}
+		if len(r.errors) != 1 || !strings.HasPrefix(r.errors[0], tc.error) {
+			t.Fatalf("Bad: %s\n  Expected: %q\n       Got: %q", tc.name, tc.error, r.errors)
+		}
+	}
+
+	r := &recorder{}
+	AssertEquivalent(r, expected, changed, "role %s", "app")
+	if !strings.HasSuffix(r.errors[0], "\nMessages: role app") {
+		t.Fatalf("Bad: message\n  Expected: formatted message\n       Got: %s", r.errors[0])
+	}
+}
+
+func TestBeEquivalentPolicy(t *testing.T) {
+	m := BeEquivalentPolicy(expected)
+
+	ok, err := m.Match([]byte(reordered))
+	if err != nil || !ok {
+		t.Fatalf("Bad: equivalent policy\n  Expected: match\n       Got: %t, %v", ok, err)
+	}
+	if msg := m.NegatedFailureMessage(reordered); !strings.Contains(msg, "not to be equivalent") {
+		t.Fatalf("Bad: negated failure message\n  Got: %s", msg)
+	}
+
+	ok, err = m.Match(changed)
+	if err != nil || ok {
+		t.Fatalf("Bad: changed policy\n  Expected: no match\n       Got: %t, %v", ok, err)
+	}
+	if msg := m.FailureMessage(changed); !strings.Contains(msg, "s3:ListBucket") {
+		t.Fatalf("Bad: failure message\n  Expected: difference\n       Got: %s", msg)
+	}
+
+	if _, err := m.Match(42); err == nil {
+		t.Fatalf("Bad: non-string value\n  Expected: error\n       Got: none")
+	}
+}
@@ -0,0 +1,189 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// PolicyKind identifies the AWS service a policy is submitted to, which
+// determines how the service rewrites it.
+type PolicyKind int
+
+const (
+	// IdentityPolicy is an IAM managed or inline policy. IAM returns
+	// these as submitted.
+	IdentityPolicy PolicyKind = iota
+
+	// TrustPolicy is an IAM role's assume role policy.
+	TrustPolicy
+
+	// BucketPolicy is an S3 bucket policy.
+	BucketPolicy
+
+	// QueuePolicy is an SQS queue policy.
+	QueuePolicy
+
+	// TopicPolicy is an SNS topic policy.
+	TopicPolicy
+
+	// KeyPolicy is a KMS key policy.
+	KeyPolicy
+)
+
+func (k PolicyKind) String() string {
+	switch k {
+	case IdentityPolicy:
+		return "IdentityPolicy"
+	case TrustPolicy:
+		return "TrustPolicy"
+	case BucketPolicy:
+		return "BucketPolicy"
+	case QueuePolicy:
+		return "QueuePolicy"
+	case TopicPolicy:
+		return "TopicPolicy"
+	case KeyPolicy:
+		return "KeyPolicy"
+	default:
+		return fmt.Sprintf("PolicyKind(%d)", int(k))
+	}
+}
+
+// normalization describes the rewrites a service applies to the policies
+// submitted to it.
+type normalization struct {
+	// expandAccountIDs replaces account ID principals with the ARN of the
+	// account's root user.
+	expandAccountIDs bool
+
+	// collapse writes single member arrays as single values and drops
+	// empty elements. Services that collapse also write members in the
+	// order the console shows them and remove whitespace, but keep the
+	// order and duplicates of array elements.
+	collapse bool
+}
+
+var normalizations = map[PolicyKind]normalization{
+	IdentityPolicy: {},
+	TrustPolicy:    {expandAccountIDs: true},
+	BucketPolicy:   {expandAccountIDs: true, collapse: true},
+	QueuePolicy:    {expandAccountIDs: true, collapse: true},
+	TopicPolicy:    {expandAccountIDs: true, collapse: true},
+	KeyPolicy:      {expandAccountIDs: true},
+}
+
+// PredictAWSNormalized returns the form AWS is expected to return policy
+// in once it has been submitted as a policy of the given kind, so that the
+// prediction can be stored in place of the submitted document and later
+// compared without a diff.
+//
+// The known server-side rewrites are applied: account ID principals are
+// expanded to root user ARNs in every kind but IdentityPolicy, and S3, SQS
+// and SNS collapse single member arrays to single values, drop empty
+// elements and write members in the order the console shows them. Array
+// elements keep their submitted order and duplicates. The partition of
+// the root user ARNs is taken from the first ARN in the policy, defaulting
+// to "aws". Rewrites that depend on account state, such as IAM replacing
+// the ARN of a deleted role with its unique ID, can't be predicted.
+//
+// The result is compact JSON, so it differs in whitespace from what
+// services that keep the submitted layout return. It is always equivalent
+// to policy. Problems with the document are reported as a *ParseError.
+func PredictAWSNormalized(policy string, kind PolicyKind) (string, error) {
+	n, ok := normalizations[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown policy kind %s", kind)
+	}
+
+	doc, err := parsePolicy(policy)
+	if err != nil {
+		return "", err
+	}
+
+	if n.expandAccountIDs {
+		doc = expandAccountIDs(doc, documentPartition(doc))
+	}
+
+	e := encoder{marshalOptions: marshalOptions{preserveShape: true, collapse: n.collapse}}
+	e.document(doc)
+	return e.String(), nil
+}
+
+// expandAccountIDs returns a copy of doc with account ID principals
+// replaced by root user ARNs.
+func expandAccountIDs(doc *policyDocument, partition string) *policyDocument {
+	expand := func(principal string) string {
+		if accountIDRegex.MatchString(principal) {
+			return arn.ARN{Partition: partition, Service: "iam", AccountID: principal, Resource: "root"}.String()
+		}
+		return principal
+	}
+
+	expanded := *doc
+	expanded.Statements = make([]*policyStatement, len(doc.Statements))
+	for i, statement := range doc.Statements {
+		if statement == nil {
+			continue
+		}
+		c := *statement
+		c.Principals = expandAWSPrincipals(statement.Principals, expand)
+		c.NotPrincipals = expandAWSPrincipals(statement.NotPrincipals, expand)
+		expanded.Statements[i] = &c
+	}
+	return &expanded
+}
+
+// expandAWSPrincipals returns a copy of principals with f applied to the
+// AWS principals only, as service and federated principals are never
+// account IDs.
+func expandAWSPrincipals(principals *principalsBlock, f func(string) string) *principalsBlock {
+	if principals == nil || principals.Bare {
+		return principals
+	}
+
+	rewritten := &principalsBlock{}
+	if principals.Types != nil {
+		rewritten.Types = make(map[string]valueList, len(principals.Types))
+		for principalType, values := range principals.Types {
+			if principalType == "AWS" {
+				values = values.rewrite(f)
+			}
+			rewritten.Types[principalType] = values
+		}
+	}
+	return rewritten
+}
+
+// documentPartition returns the partition of the first ARN found in the
+// policy's principals and resources, or "aws" if there is none.
+func documentPartition(doc *policyDocument) string {
+	for _, statement := range doc.Statements {
+		if statement == nil {
+			continue
+		}
+
+		lists := []valueList{statement.Resources, statement.NotResources}
+		for _, principals := range []*principalsBlock{statement.Principals, statement.NotPrincipals} {
+			if principals != nil {
+				lists = append(lists, principals.Types["AWS"])
+			}
+		}
+
+		for _, list := range lists {
+			for i, v := range list.Values {
+				if list.kind(i) != stringValue {
+					continue
+				}
+				if parsed, err := arn.Parse(v); err == nil {
+					return parsed.Partition
+				}
+			}
+		}
+	}
+	return "aws"
+}
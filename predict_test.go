@@ -0,0 +1,87 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestPredictAWSNormalized(t *testing.T) {
+	cases := []struct {
+		name      string
+		kind      PolicyKind
+		submitted string
+		returned  string
+	}{
+		{
+			name:      "Trust policy",
+			kind:      TrustPolicy,
+			submitted: policyTest61a,
+			returned:  policyTest61b,
+		},
+		{
+			name:      "Bucket policy",
+			kind:      BucketPolicy,
+			submitted: policyTest62a,
+			returned:  policyTest62b,
+		},
+		{
+			name:      "Queue policy",
+			kind:      QueuePolicy,
+			submitted: policyTest63a,
+			returned:  policyTest63b,
+		},
+		{
+			name:      "Identity policy",
+			kind:      IdentityPolicy,
+			submitted: policyTest64a,
+			returned:  policyTest64b,
+		},
+		{
+			name:      "Key policy",
+			kind:      KeyPolicy,
+			submitted: policyTest65a,
+			returned:  policyTest65b,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			predicted, err := PredictAWSNormalized(tc.submitted, tc.kind)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			// The returned documents are compacted rather than compared with
+			// jsonEqual so that the order of members is checked as well.
+			var returned bytes.Buffer
+			if err := json.Compact(&returned, []byte(tc.returned)); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if predicted != returned.String() {
+				t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s\n", tc.name, returned.String(), predicted)
+			}
+
+			equivalent, err := PoliciesAreEquivalent(tc.submitted, predicted)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !equivalent {
+				t.Fatalf("Expected prediction to be equivalent to submitted policy: %s", predicted)
+			}
+		})
+	}
+}
+
+func TestPredictAWSNormalizedErrors(t *testing.T) {
+	if _, err := PredictAWSNormalized(policyTest1, PolicyKind(42)); err == nil || err.Error() != "unknown policy kind PolicyKind(42)" {
+		t.Fatalf("Bad error: %v", err)
+	}
+	if _, err := PredictAWSNormalized(policyTest0, BucketPolicy); err == nil {
+		t.Fatal("Expected error, none produced")
+	}
+}
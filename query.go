@@ -0,0 +1,154 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"iter"
+	"strings"
+)
+
+// StatementFilter reports whether a statement should be selected by
+// Policy.Select.
+type StatementFilter func(*Statement) bool
+
+// Select returns an iterator over the statements that match every filter,
+// in document order. With no filters every statement is selected.
+//
+// For example, the statements that allow iam:PassRole are
+//
+//	policy.Select(EffectIs("Allow"), MatchesAction("iam:PassRole"))
+func (p *Policy) Select(filters ...StatementFilter) iter.Seq[*Statement] {
+	return func(yield func(*Statement) bool) {
+		for statement := range p.All() {
+			if statement.matches(filters) && !yield(statement) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Statement) matches(filters []StatementFilter) bool {
+	for _, filter := range filters {
+		if filter != nil && !filter(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// EffectIs returns a filter selecting statements whose Effect is effect,
+// ignoring case.
+func EffectIs(effect string) StatementFilter {
+	return func(s *Statement) bool {
+		return strings.EqualFold(s.policyStatement().Effect, effect)
+	}
+}
+
+// MatchesAction returns a filter selecting statements that apply to
+// action: statements with an Action element matching it, or with a
+// NotAction element that doesn't. Action names are matched ignoring case,
+// and wildcards (* and ?) in the statement are expanded.
+func MatchesAction(action string) StatementFilter {
+	return func(s *Statement) bool {
+		return elementMatches(s.policyStatement().Actions, s.policyStatement().NotActions, action, true)
+	}
+}
+
+// MatchesResource returns a filter selecting statements that apply to
+// resource: statements with a Resource element matching it, or with a
+// NotResource element that doesn't. Wildcards (* and ?) in the statement
+// are expanded, so "arn:aws:s3:::bucket/key" is matched by
+// "arn:aws:s3:::bucket/*".
+func MatchesResource(resource string) StatementFilter {
+	return func(s *Statement) bool {
+		return elementMatches(s.policyStatement().Resources, s.policyStatement().NotResources, resource, false)
+	}
+}
+
+// MatchesPrincipal returns a filter selecting statements that apply to a
+// principal of the given type (such as "AWS" or "Service"): statements
+// with a Principal element that names it or the "*" wildcard, or with a
+// NotPrincipal element that doesn't name it. Principals are compared the
+// way PoliciesAreEquivalent compares them, so an account ID matches its
+// root user ARN.
+func MatchesPrincipal(principalType, principal string) StatementFilter {
+	return func(s *Statement) bool {
+		if s.policyStatement().NotPrincipals != nil {
+			return !principalNamed(s.policyStatement().NotPrincipals, principalType, principal)
+		}
+		return principalNamed(s.policyStatement().Principals, principalType, principal)
+	}
+}
+
+func elementMatches(element, notElement valueList, value string, foldCase bool) bool {
+	if element.Values != nil {
+		return anyMatch(element.texts(), value, foldCase)
+	}
+	if notElement.Values != nil {
+		return !anyMatch(notElement.texts(), value, foldCase)
+	}
+	return false
+}
+
+func anyMatch(patterns []string, value string, foldCase bool) bool {
+	for _, pattern := range patterns {
+		if wildcardMatch(pattern, value, foldCase) {
+			return true
+		}
+	}
+	return false
+}
+
+func principalNamed(principals *principalsBlock, principalType, principal string) bool {
+	if principals == nil {
+		return false
+	}
+	if principalType != "AWS" {
+		for _, p := range principals.Types["AWS"].set() {
+			if p == "*" {
+				return true
+			}
+		}
+	}
+
+	for _, p := range principals.Types[principalType].set() {
+		if p == "*" || samePrincipal(principalType, p, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch reports whether value matches pattern, in which * matches
+// any sequence of characters and ? matches any single character, as in
+// IAM policy elements.
+func wildcardMatch(pattern, value string, foldCase bool) bool {
+	if foldCase {
+		pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	}
+
+	p, v := 0, 0
+	star, mark := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, v
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			v = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
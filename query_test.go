@@ -0,0 +1,177 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicySelect(t *testing.T) {
+	policy, err := ParsePolicy(queryPolicyTest1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name     string
+		filters  []StatementFilter
+		expected []string
+	}{
+		{
+			name:     "No filters",
+			expected: []string{"PassRole", "DenyOutsideBucket", "PublicRead", "TrustAccount"},
+		},
+		{
+			name:     "Effect",
+			filters:  []StatementFilter{EffectIs("deny")},
+			expected: []string{"DenyOutsideBucket"},
+		},
+		{
+			name:     "Allowed action",
+			filters:  []StatementFilter{EffectIs("Allow"), MatchesAction("IAM:passrole")},
+			expected: []string{"PassRole"},
+		},
+		{
+			name:     "Action matched by NotAction",
+			filters:  []StatementFilter{MatchesAction("ec2:RunInstances")},
+			expected: []string{"DenyOutsideBucket"},
+		},
+		{
+			name:     "Action wildcard",
+			filters:  []StatementFilter{MatchesAction("s3:GetObjectVersion")},
+			expected: []string{"PublicRead"},
+		},
+		{
+			name:     "Resource wildcard",
+			filters:  []StatementFilter{EffectIs("Allow"), MatchesResource("arn:aws:iam::123456789012:role/app-web")},
+			expected: []string{"PassRole"},
+		},
+		{
+			name:     "Resource matched by NotResource",
+			filters:  []StatementFilter{MatchesResource("arn:aws:s3:::other-bucket/key")},
+			expected: []string{"DenyOutsideBucket"},
+		},
+		{
+			name:     "Bucket",
+			filters:  []StatementFilter{MatchesResource("arn:aws:s3:::bucket/key")},
+			expected: []string{"PublicRead"},
+		},
+		{
+			name:     "Account ID principal matches root ARN and wildcard",
+			filters:  []StatementFilter{MatchesPrincipal("AWS", "123456789012")},
+			expected: []string{"PublicRead", "TrustAccount"},
+		},
+		{
+			name:     "Root ARN principal matches root ARN in the same partition",
+			filters:  []StatementFilter{MatchesPrincipal("AWS", "arn:aws:iam::123456789012:root")},
+			expected: []string{"PublicRead", "TrustAccount"},
+		},
+		{
+			name:     "Root ARN principal in another partition",
+			filters:  []StatementFilter{MatchesPrincipal("AWS", "arn:aws-cn:iam::123456789012:root")},
+			expected: []string{"PublicRead"},
+		},
+		{
+			name:     "Service principal matches wildcard",
+			filters:  []StatementFilter{MatchesPrincipal("Service", "ec2.amazonaws.com")},
+			expected: []string{"PublicRead"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sids []string
+			for statement := range policy.Select(tc.filters...) {
+				sids = append(sids, statement.Sid())
+			}
+
+			if !reflect.DeepEqual(sids, tc.expected) {
+				t.Fatalf("Bad: %s\n  Expected: %v\n       Got: %v\n", tc.name, tc.expected, sids)
+			}
+		})
+	}
+}
+
+func TestPolicySelectStopsEarly(t *testing.T) {
+	policy, err := ParsePolicy(queryPolicyTest1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	n := 0
+	for range policy.Select() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Fatalf("Bad: expected 1 iteration, got %d", n)
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		value    string
+		foldCase bool
+		match    bool
+	}{
+		{"*", "anything", false, true},
+		{"s3:Get*", "s3:GetObject", false, true},
+		{"s3:Get*", "s3:PutObject", false, false},
+		{"s3:get*", "S3:GetObject", true, true},
+		{"s3:get*", "S3:GetObject", false, false},
+		{"arn:aws:s3:::bucket/*/key", "arn:aws:s3:::bucket/a/b/key", false, true},
+		{"arn:aws:s3:::bucket/?", "arn:aws:s3:::bucket/a", false, true},
+		{"arn:aws:s3:::bucket/?", "arn:aws:s3:::bucket/ab", false, false},
+		{"a*b*c", "abbbc", false, true},
+		{"a*b*c", "abbbd", false, false},
+		{"", "", false, true},
+	}
+
+	for _, tc := range cases {
+		if got := wildcardMatch(tc.pattern, tc.value, tc.foldCase); got != tc.match {
+			t.Errorf("wildcardMatch(%q, %q, %t)\n  Expected: %t\n       Got: %t", tc.pattern, tc.value, tc.foldCase, tc.match, got)
+		}
+	}
+}
+
+const queryPolicyTest1 = `{
+  "Version": "2012-10-17",
+  "Id": "query",
+  "Statement": [
+    {
+      "Sid": "PassRole",
+      "Effect": "Allow",
+      "Action": "iam:PassRole",
+      "Resource": ["arn:aws:iam::123456789012:role/app-*", "arn:aws:iam::123456789012:role/ops", "arn:aws:iam::123456789012:role/ops"],
+      "Condition": {
+        "StringEquals": {"iam:PassedToService": "ec2.amazonaws.com"},
+        "NumericEquals": {"custom:Count": 1.50},
+        "Bool": {}
+      }
+    },
+    {
+      "Sid": "DenyOutsideBucket",
+      "Effect": "Deny",
+      "NotAction": ["iam:*", "s3:*"],
+      "NotResource": "arn:aws:s3:::bucket/*"
+    },
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "s3:GetObject*",
+      "Resource": "arn:aws:s3:::bucket/*"
+    },
+    {
+      "Sid": "TrustAccount",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:root"},
+      "Action": "sts:AssumeRole"
+    },
+    null
+  ]
+}`
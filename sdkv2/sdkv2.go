@@ -0,0 +1,106 @@
+// Package sdkv2 provides terraform-plugin-sdk/v2 schema functions for
+// attributes holding an AWS policy document, so that providers don't need
+// to write their own around PoliciesAreEquivalent:
+//
+//	"policy": {
+//		Type:             schema.TypeString,
+//		Required:         true,
+//		DiffSuppressFunc: sdkv2.SuppressEquivalentPolicyDiffs,
+//		StateFunc:        sdkv2.NormalizePolicy,
+//		ValidateDiagFunc: sdkv2.ValidatePolicy,
+//	},
+package sdkv2
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"fmt"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var (
+	_ schema.SchemaDiffSuppressFunc = SuppressEquivalentPolicyDiffs
+	_ schema.SchemaStateFunc        = NormalizePolicy
+	_ schema.SchemaValidateDiagFunc = ValidatePolicy
+)
+
+// SuppressEquivalentPolicyDiffs suppresses the difference between two
+// policy documents that PoliciesAreEquivalent considers equivalent. A
+// difference involving a document that can't be parsed is not suppressed.
+func SuppressEquivalentPolicyDiffs(k, oldValue, newValue string, d *schema.ResourceData) bool {
+	equivalent, err := awspolicy.PoliciesAreEquivalent(oldValue, newValue)
+	if err != nil {
+		return false
+	}
+	return equivalent
+}
+
+// NormalizePolicy returns the canonical form of a policy document, as
+// written by Policy.Marshal, so that equivalent documents are stored in
+// state identically. Like Marshal, it leaves out members of the document
+// and its statements that aren't policy elements, which
+// PoliciesAreEquivalent ignores. A value that is not a valid policy
+// document is returned unchanged, leaving ValidatePolicy to report it.
+func NormalizePolicy(v interface{}) string {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return s
+	}
+
+	policy, err := awspolicy.ParsePolicy(s)
+	if err != nil {
+		return s
+	}
+	canonical, err := policy.Marshal()
+	if err != nil {
+		return s
+	}
+	return string(canonical)
+}
+
+// ValidatePolicy reports an error for a value that is not a valid policy
+// document. The diagnostic is attached to the attribute at path, and its
+// detail names the offending element of the policy, such as
+// Statement[1].Effect, and its line and column in the document.
+func ValidatePolicy(v interface{}, path cty.Path) diag.Diagnostics {
+	s, ok := v.(string)
+	if !ok {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid AWS policy document",
+			Detail:        fmt.Sprintf("Expected a string, got %T.", v),
+			AttributePath: path,
+		}}
+	}
+
+	if _, err := awspolicy.ParsePolicy(s); err != nil {
+		return diag.Diagnostics{policyDiagnostic(err, path)}
+	}
+	return nil
+}
+
+func policyDiagnostic(err error, path cty.Path) diag.Diagnostic {
+	d := diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       "Invalid AWS policy document",
+		Detail:        err.Error(),
+		AttributePath: path,
+	}
+
+	var parseErr *awspolicy.ParseError
+	if errors.As(err, &parseErr) {
+		if parseErr.Syntax {
+			d.Summary = "Invalid JSON in AWS policy document"
+		} else if parseErr.Path != "" {
+			d.Summary = "Invalid " + parseErr.Path + " in AWS policy document"
+		}
+	}
+	return d
+}
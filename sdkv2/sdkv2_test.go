@@ -0,0 +1,153 @@
+package sdkv2
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+const policy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["s3:ListBucket", "s3:GetObject"],
+      "Resource": ["*"]
+    }
+  ]
+}`
+
+func TestSuppressEquivalentPolicyDiffs(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, new string
+		suppress bool
+	}{
+		{
+			name:     "reordered and collapsed",
+			old:      policy,
+			new:      `{"Statement":{"Resource":"*","Action":["s3:GetObject","s3:ListBucket"],"Effect":"Allow"},"Version":"2012-10-17"}`,
+			suppress: true,
+		},
+		{
+			name: "different action",
+			old:  policy,
+			new:  `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`,
+		},
+		{
+			name:     "empty",
+			old:      "",
+			new:      "",
+			suppress: true,
+		},
+		{
+			name: "invalid",
+			old:  policy,
+			new:  `{"Statement":`,
+		},
+	}
+
+	for _, tc := range cases {
+		if got := SuppressEquivalentPolicyDiffs("policy", tc.old, tc.new, nil); got != tc.suppress {
+			t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t", tc.name, tc.suppress, got)
+		}
+	}
+}
+
+func TestNormalizePolicy(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "canonical form",
+			value: policy,
+			want:  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:ListBucket"],"Resource":"*"}]}`,
+		},
+		{
+			name:  "unknown members",
+			value: `{"Version":"2012-10-17","Comment":"read only","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*","Note":1}}`,
+			want:  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+		},
+		{
+			name:  "empty",
+			value: "",
+			want:  "",
+		},
+		{
+			name:  "invalid",
+			value: `{"Statement":`,
+			want:  `{"Statement":`,
+		},
+	}
+
+	for _, tc := range cases {
+		if got := NormalizePolicy(tc.value); got != tc.want {
+			t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   interface{}
+		summary string
+		detail  string
+	}{
+		{
+			name:  "valid",
+			value: policy,
+		},
+		{
+			name:    "syntax error",
+			value:   "{\n  \"Statement\": [\n",
+			summary: "Invalid JSON in AWS policy document",
+			detail:  "unexpected end of JSON input at line 2",
+		},
+		{
+			name:    "bad statement",
+			value:   "{\n  \"Statement\": [\n    {\"Effect\": \"Allow\"},\n    {\"Effect\": [\"Deny\"]}\n  ]\n}",
+			summary: "Invalid Statement[1].Effect in AWS policy document",
+			detail:  "at line 4",
+		},
+		{
+			name:    "not a string",
+			value:   42,
+			summary: "Invalid AWS policy document",
+			detail:  "Expected a string, got int.",
+		},
+	}
+
+	for _, tc := range cases {
+		path := cty.GetAttrPath("statement").IndexInt(0).GetAttr("policy")
+		diags := ValidatePolicy(tc.value, path)
+
+		if tc.summary == "" {
+			if diags.HasError() {
+				t.Fatalf("Bad: %s\n  Unexpected diagnostics: %v", tc.name, diags)
+			}
+			continue
+		}
+
+		if len(diags) != 1 {
+			t.Fatalf("Bad: %s\n  Expected: one diagnostic\n       Got: %v", tc.name, diags)
+		}
+		d := diags[0]
+		if !d.AttributePath.Equals(path) {
+			t.Fatalf("Bad: %s\n  Expected path: %#v\n       Got: %#v", tc.name, path, d.AttributePath)
+		}
+		if d.Summary != tc.summary {
+			t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s", tc.name, tc.summary, d.Summary)
+		}
+		if !strings.Contains(d.Detail, tc.detail) {
+			t.Fatalf("Bad: %s\n  Expected: %q in\n       Got: %s", tc.name, tc.detail, d.Detail)
+		}
+	}
+}
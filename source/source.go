@@ -0,0 +1,185 @@
+// Package source converts policy documents read from formats other than
+// JSON into policies, keeping track of where each value came from so that
+// problems are reported against the original source text.
+//
+// It is the shared base of the hclpolicy and yamlpolicy modules and can be
+// used to add other formats. A parser converts its syntax tree into a
+// Value, giving each value the Pos it was read from, and passes it to
+// Document.Policy.
+package source
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// Pos is a position in the source text. Line and Column are 1-based and
+// Column is 0 if only the line is known.
+type Pos struct {
+	Offset int64
+	Line   int
+	Column int
+}
+
+// PosOf returns the position of a 1-based line and column in src.
+func PosOf(src []byte, line, column int) Pos {
+	offset := 0
+	for l := 1; l < line && offset < len(src); l++ {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			offset = len(src)
+			break
+		}
+		offset += i + 1
+	}
+	if column > 0 {
+		offset = min(offset+column-1, len(src))
+	}
+	return Pos{Offset: int64(offset), Line: line, Column: column}
+}
+
+// Kind is the JSON type of a Value.
+type Kind int
+
+const (
+	Null Kind = iota
+	String
+	Number
+	Bool
+	Object
+	Array
+)
+
+// Value is a JSON value read from another format.
+type Value struct {
+	Pos  Pos
+	Kind Kind
+
+	// Text is the contents of a string, the literal text of a number or
+	// "true" or "false".
+	Text string
+
+	// Keys holds the keys of an object, and Elems its values in the same
+	// order, or the elements of an array.
+	Keys  []string
+	Elems []*Value
+}
+
+// Document converts values read from a source format into a policy.
+type Document struct {
+	// Format names the source format in errors, such as "YAML".
+	Format string
+
+	positions map[string]Pos
+}
+
+// Errorf returns a *awspolicy.ParseError for a problem with the value at
+// path in the source text.
+func (d *Document) Errorf(pos Pos, path string, format string, args ...interface{}) error {
+	return d.newError(pos, path, fmt.Errorf(format, args...), false)
+}
+
+// SyntaxError returns a *awspolicy.ParseError for source text that is not
+// valid in the source format.
+func (d *Document) SyntaxError(pos Pos, err error) error {
+	return d.newError(pos, "", err, true)
+}
+
+func (d *Document) newError(pos Pos, path string, err error, syntax bool) error {
+	return &awspolicy.ParseError{
+		Path:   path,
+		Offset: pos.Offset,
+		Line:   pos.Line,
+		Column: pos.Column,
+		Syntax: syntax,
+		Format: d.Format,
+		Err:    err,
+	}
+}
+
+// Policy writes v as JSON and parses it as a policy. Problems with the
+// policy are reported at the position of the offending value in the
+// source text.
+func (d *Document) Policy(v *Value) (*awspolicy.Policy, error) {
+	d.positions = make(map[string]Pos)
+
+	var b strings.Builder
+	d.write(&b, v, "")
+
+	policy, err := awspolicy.ParsePolicy(b.String())
+	if err != nil {
+		var parseErr *awspolicy.ParseError
+		if !errors.As(err, &parseErr) {
+			return nil, err
+		}
+		return nil, d.newError(d.position(parseErr.Path), parseErr.Path, parseErr.Err, parseErr.Syntax)
+	}
+	return policy, nil
+}
+
+// position returns the position of the value at path, or of the nearest
+// value containing it.
+func (d *Document) position(path string) Pos {
+	for {
+		if pos, ok := d.positions[path]; ok {
+			return pos
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return d.positions[""]
+		}
+		path = path[:i]
+	}
+}
+
+func (d *Document) write(b *strings.Builder, v *Value, path string) {
+	d.positions[path] = v.Pos
+
+	switch v.Kind {
+	case Null:
+		b.WriteString("null")
+	case String:
+		b.WriteString(quote(v.Text))
+	case Number, Bool:
+		b.WriteString(v.Text)
+	case Object:
+		b.WriteByte('{')
+		for i, key := range v.Keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quote(key))
+			b.WriteByte(':')
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			d.write(b, v.Elems[i], child)
+		}
+		b.WriteByte('}')
+	case Array:
+		b.WriteByte('[')
+		for i, elem := range v.Elems {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			d.write(b, elem, fmt.Sprintf("%s[%d]", path, i))
+		}
+		b.WriteByte(']')
+	}
+}
+
+func quote(s string) string {
+	// Marshaling a string can't fail.
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,190 @@
+package source
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+func TestPosOf(t *testing.T) {
+	src := []byte("first\nsecond line\n\nfourth")
+
+	cases := []struct {
+		name     string
+		line     int
+		column   int
+		expected Pos
+	}{
+		{
+			name:     "Start",
+			line:     1,
+			column:   1,
+			expected: Pos{Offset: 0, Line: 1, Column: 1},
+		},
+		{
+			name:     "Column",
+			line:     2,
+			column:   8,
+			expected: Pos{Offset: 13, Line: 2, Column: 8},
+		},
+		{
+			name:     "Line only",
+			line:     4,
+			expected: Pos{Offset: 19, Line: 4},
+		},
+		{
+			name:     "Empty line",
+			line:     3,
+			column:   1,
+			expected: Pos{Offset: 18, Line: 3, Column: 1},
+		},
+		{
+			name:     "Past the end of the line",
+			line:     4,
+			column:   20,
+			expected: Pos{Offset: 25, Line: 4, Column: 20},
+		},
+		{
+			name:     "Past the last line",
+			line:     9,
+			column:   1,
+			expected: Pos{Offset: 25, Line: 9, Column: 1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pos := PosOf(src, tc.line, tc.column)
+			if pos != tc.expected {
+				t.Fatalf("Bad: %s\n  Expected: %+v\n       Got: %+v\n", tc.name, tc.expected, pos)
+			}
+		})
+	}
+}
+
+// at returns a value of kind at the given line, with the offset of the
+// start of the line in a document of 10 byte lines.
+func at(line int, kind Kind, text string) *Value {
+	return &Value{Pos: Pos{Offset: int64(line-1) * 10, Line: line}, Kind: kind, Text: text}
+}
+
+func object(line int, keys []string, elems ...*Value) *Value {
+	v := at(line, Object, "")
+	v.Keys = keys
+	v.Elems = elems
+	return v
+}
+
+func array(line int, elems ...*Value) *Value {
+	v := at(line, Array, "")
+	v.Elems = elems
+	return v
+}
+
+func TestDocumentPolicy(t *testing.T) {
+	v := object(1, []string{"Version", "Statement"},
+		at(2, String, "2012-10-17"),
+		array(3, object(4, []string{"Effect", "Action", "Resource", "Condition"},
+			at(5, String, "Allow"),
+			array(6, at(7, String, "s3:GetObject"), at(8, String, `s3:Get"Bucket"*`)),
+			at(9, String, "*"),
+			object(10, []string{"NumericLessThan"},
+				object(11, []string{"s3:max-keys"}, at(12, Number, "10"))),
+		)),
+	)
+
+	d := Document{Format: "Test"}
+	policy, err := d.Policy(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected, err := awspolicy.ParsePolicy(`{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Action": ["s3:GetObject", "s3:Get\"Bucket\"*"],
+    "Resource": "*",
+    "Condition": {"NumericLessThan": {"s3:max-keys": 10}}
+  }]
+}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !policy.EquivalentTo(expected) {
+		got, _ := policy.Marshal()
+		t.Fatalf("Bad: Expected equivalent to JSON policy, got: %s", got)
+	}
+}
+
+func TestDocumentPolicyErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    *Value
+		path     string
+		line     int
+		errorStr string
+	}{
+		{
+			name: "Invalid value",
+			value: object(1, []string{"Version", "Statement"},
+				at(2, String, "2012-10-17"),
+				array(3, object(4, []string{"Action", "Effect"},
+					at(5, String, "s3:GetObject"),
+					at(6, Number, "1"),
+				)),
+			),
+			path:     "Statement[0].Effect",
+			line:     6,
+			errorStr: "Test: Statement[0].Effect: expected string, found number at line 6",
+		},
+		{
+			name:     "Document",
+			value:    at(1, String, "policy"),
+			line:     1,
+			errorStr: "Test: expected policy document object, found string at line 1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Document{Format: "Test"}
+			_, err := d.Policy(tc.value)
+
+			var parseErr *awspolicy.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *awspolicy.ParseError, got: %v", err)
+			}
+			if parseErr.Path != tc.path || parseErr.Line != tc.line || parseErr.Format != "Test" {
+				t.Fatalf("Bad: %s\n  Expected: %s at line %d\n       Got: %s at line %d in %q\n", tc.name, tc.path, tc.line, parseErr.Path, parseErr.Line, parseErr.Format)
+			}
+			if !strings.HasPrefix(err.Error(), tc.errorStr) {
+				t.Fatalf("Bad: %s\n  Expected prefix: %s\n              Got: %s\n", tc.name, tc.errorStr, err)
+			}
+		})
+	}
+}
+
+func TestDocumentErrorf(t *testing.T) {
+	d := Document{Format: "Test"}
+	pos := PosOf([]byte("a\nbc"), 2, 2)
+
+	err := d.Errorf(pos, "Statement", "unsupported %s", "tag")
+	expected := "Test: Statement: unsupported tag at line 2, column 2"
+	if err.Error() != expected {
+		t.Fatalf("Bad:\n  Expected: %s\n       Got: %s\n", expected, err)
+	}
+
+	err = d.SyntaxError(pos, errors.New("unexpected token"))
+	var parseErr *awspolicy.ParseError
+	if !errors.As(err, &parseErr) || !parseErr.Syntax || parseErr.Offset != 3 {
+		t.Fatalf("Bad: Expected syntax error at offset 3, got: %#v", err)
+	}
+}
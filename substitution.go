@@ -0,0 +1,167 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// substitutions holds the account ID, partition, region and unique ID
+// mappings that are applied to both policies before they are compared.
+type substitutions struct {
+	accountIDs map[string]string
+	partitions map[string]string
+	regions    map[string]string
+	uniqueIDs  map[string]string
+}
+
+func (s substitutions) empty() bool {
+	return len(s.accountIDs) == 0 && len(s.partitions) == 0 && len(s.regions) == 0 && len(s.uniqueIDs) == 0
+}
+
+// arn rewrites the partition, region and account ID sections of value if
+// it is an ARN. Any other value is returned unchanged.
+func (s substitutions) arn(value string) string {
+	parsed, err := arn.Parse(value)
+	if err != nil {
+		return value
+	}
+
+	if v, ok := s.partitions[parsed.Partition]; ok {
+		parsed.Partition = v
+	}
+	if v, ok := s.regions[parsed.Region]; ok {
+		parsed.Region = v
+	}
+	if v, ok := s.accountIDs[parsed.AccountID]; ok {
+		parsed.AccountID = v
+	}
+
+	return parsed.String()
+}
+
+// principal rewrites a single principal, which may be a bare account ID,
+// an IAM unique ID or an ARN.
+func (s substitutions) principal(value string) string {
+	if v, ok := s.uniqueIDs[value]; ok {
+		value = v
+	}
+
+	if accountIDRegex.MatchString(value) {
+		if v, ok := s.accountIDs[value]; ok {
+			return v
+		}
+		return value
+	}
+
+	return s.arn(value)
+}
+
+// apply returns a copy of doc with its ARNs and account IDs rewritten.
+// doc itself is never modified.
+func (s substitutions) apply(doc *policyDocument) *policyDocument {
+	if s.empty() || doc == nil {
+		return doc
+	}
+
+	rewritten := &policyDocument{
+		Version:         doc.Version,
+		Id:              doc.Id,
+		Statements:      make([]*policyStatement, len(doc.Statements)),
+		SingleStatement: doc.SingleStatement,
+		Members:         doc.Members,
+		Unknown:         doc.Unknown,
+	}
+
+	for i, statement := range doc.Statements {
+		if statement == nil {
+			continue
+		}
+
+		c := *statement
+		c.Resources = statement.Resources.rewrite(s.arn)
+		c.NotResources = statement.NotResources.rewrite(s.arn)
+		c.Principals = statement.Principals.rewrite(s.principal)
+		c.NotPrincipals = statement.NotPrincipals.rewrite(s.principal)
+
+		if statement.Conditions != nil {
+			c.Conditions = make(conditionsBlock, len(statement.Conditions))
+			for operator, condition := range statement.Conditions {
+				if condition == nil {
+					c.Conditions[operator] = nil
+					continue
+				}
+				rewrittenCondition := make(map[string]valueList, len(condition))
+				for key, values := range condition {
+					if isARNCondition(operator, key) {
+						values = values.rewrite(s.arn)
+					}
+					rewrittenCondition[key] = values
+				}
+				c.Conditions[operator] = rewrittenCondition
+			}
+		}
+
+		rewritten.Statements[i] = &c
+	}
+
+	return rewritten
+}
+
+// rewrite returns a copy of values with f applied to each value.
+func (values valueList) rewrite(f func(string) string) valueList {
+	if values.Values == nil {
+		return values
+	}
+
+	rewritten := values
+	rewritten.Values = make([]string, len(values.Values))
+	for i, v := range values.Values {
+		if values.kind(i) == stringValue {
+			v = f(v)
+		}
+		rewritten.Values[i] = v
+	}
+	return rewritten
+}
+
+// rewrite returns a copy of principals with f applied to each principal.
+func (principals *principalsBlock) rewrite(f func(string) string) *principalsBlock {
+	if principals == nil {
+		return nil
+	}
+
+	rewritten := &principalsBlock{Bare: principals.Bare}
+	if principals.Types != nil {
+		rewritten.Types = make(map[string]valueList, len(principals.Types))
+		for principalType, values := range principals.Types {
+			rewritten.Types[principalType] = values.rewrite(f)
+		}
+	}
+	return rewritten
+}
+
+// isARNCondition reports whether the values of a condition hold ARNs,
+// either because the operator is one of the ARN condition operators or
+// because the condition key names an ARN, such as aws:SourceArn.
+func isARNCondition(operator, key string) bool {
+	operator = strings.TrimSuffix(conditionOperatorBase(operator), "IfExists")
+	if strings.HasPrefix(operator, "Arn") {
+		return true
+	}
+
+	return strings.HasSuffix(strings.ToLower(key), "arn")
+}
+
+// conditionOperatorBase strips any ForAllValues: or ForAnyValue: set
+// qualifier from a condition operator.
+func conditionOperatorBase(operator string) string {
+	if i := strings.Index(operator, ":"); i >= 0 {
+		return operator[i+1:]
+	}
+	return operator
+}
@@ -0,0 +1,277 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyEquivalenceWithSubstitutions(t *testing.T) {
+	commercialToGovCloud := []Option{
+		WithAccountIDMapping(map[string]string{"111122223333": "444455556666"}),
+		WithPartitionMapping(map[string]string{"aws-us-gov": "aws"}),
+		WithRegionMapping(map[string]string{"us-gov-west-1": "us-west-2"}),
+	}
+
+	cases := []struct {
+		name       string
+		policy1    string
+		policy2    string
+		opts       []Option
+		equivalent bool
+	}{
+		{
+			name:       "Different partitions and accounts without mappings",
+			policy1:    substitutionPolicyTest1a,
+			policy2:    substitutionPolicyTest1b,
+			equivalent: false,
+		},
+		{
+			name:       "Different partitions and accounts with mappings",
+			policy1:    substitutionPolicyTest1a,
+			policy2:    substitutionPolicyTest1b,
+			opts:       commercialToGovCloud,
+			equivalent: true,
+		},
+		{
+			name:       "Mappings are applied to both policies",
+			policy1:    substitutionPolicyTest1b,
+			policy2:    substitutionPolicyTest1a,
+			opts:       commercialToGovCloud,
+			equivalent: true,
+		},
+		{
+			name:    "Partition mapping only",
+			policy1: substitutionPolicyTest1a,
+			policy2: substitutionPolicyTest1b,
+			opts: []Option{
+				WithPartitionMapping(map[string]string{"aws-us-gov": "aws"}),
+			},
+			equivalent: false,
+		},
+		{
+			name:       "Bare account ID principal",
+			policy1:    substitutionPolicyTest2a,
+			policy2:    substitutionPolicyTest2b,
+			opts:       commercialToGovCloud,
+			equivalent: true,
+		},
+		{
+			name:       "Non-ARN condition values are not rewritten",
+			policy1:    substitutionPolicyTest3a,
+			policy2:    substitutionPolicyTest3b,
+			opts:       commercialToGovCloud,
+			equivalent: false,
+		},
+		{
+			name:    "China partition",
+			policy1: substitutionPolicyTest4a,
+			policy2: substitutionPolicyTest4b,
+			opts: []Option{
+				WithPartitionMapping(map[string]string{"aws-cn": "aws"}),
+				WithRegionMapping(map[string]string{"cn-north-1": "us-east-1"}),
+			},
+			equivalent: true,
+		},
+		{
+			name:       "Unique ID without mapping",
+			policy1:    substitutionPolicyTest5a,
+			policy2:    substitutionPolicyTest5b,
+			equivalent: false,
+		},
+		{
+			name:    "Unique ID of deleted role",
+			policy1: substitutionPolicyTest5a,
+			policy2: substitutionPolicyTest5b,
+			opts: []Option{
+				WithUniqueIDMapping(map[string]string{"AROAEXAMPLEID1234567": "arn:aws:iam::111122223333:role/deleted"}),
+			},
+			equivalent: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			equal, err := PoliciesAreEquivalent(tc.policy1, tc.policy2, tc.opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if equal != tc.equivalent {
+				t.Fatalf("Bad: %s\n  Expected: %t\n       Got: %t\n", tc.name, tc.equivalent, equal)
+			}
+		})
+	}
+}
+
+func TestWithAccountIDMappingCopiesMapping(t *testing.T) {
+	mapping := map[string]string{"111122223333": "444455556666"}
+	opt := WithAccountIDMapping(mapping)
+	mapping["111122223333"] = "777788889999"
+
+	o := newOptions([]Option{opt})
+	if got := o.substitutions.accountIDs["111122223333"]; got != "444455556666" {
+		t.Fatalf("Bad: expected mapping to be copied, got %q", got)
+	}
+}
+
+func TestSubstitutionsDoNotModifyDocument(t *testing.T) {
+	doc, err := parsePolicy(substitutionPolicyTest1a)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	original, err := parsePolicy(substitutionPolicyTest1a)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	o := newOptions([]Option{
+		WithAccountIDMapping(map[string]string{"111122223333": "444455556666"}),
+		WithPartitionMapping(map[string]string{"aws": "aws-us-gov"}),
+	})
+	rewritten := o.substitutions.apply(doc)
+
+	if !reflect.DeepEqual(doc, original) {
+		t.Fatalf("Document was modified:\n%v", doc)
+	}
+	if reflect.DeepEqual(rewritten, original) {
+		t.Fatal("Expected rewritten document to differ from the original")
+	}
+}
+
+const substitutionPolicyTest1a = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": "arn:aws:iam::111122223333:role/deployer"
+      },
+      "Action": "sqs:SendMessage",
+      "Resource": "arn:aws:sqs:us-west-2:111122223333:queue",
+      "Condition": {
+        "ArnEquals": {
+          "aws:SourceArn": "arn:aws:sns:us-west-2:111122223333:topic"
+        }
+      }
+    },
+    {
+      "Effect": "Deny",
+      "Action": "s3:*",
+      "NotResource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+      "Condition": {
+        "StringLike": {
+          "aws:PrincipalArn": "arn:aws:iam::111122223333:role/*"
+        }
+      }
+    }
+  ]
+}`
+
+const substitutionPolicyTest1b = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": "arn:aws-us-gov:iam::444455556666:role/deployer"
+      },
+      "Action": "sqs:SendMessage",
+      "Resource": "arn:aws-us-gov:sqs:us-gov-west-1:444455556666:queue",
+      "Condition": {
+        "ArnEquals": {
+          "aws:SourceArn": "arn:aws-us-gov:sns:us-gov-west-1:444455556666:topic"
+        }
+      }
+    },
+    {
+      "Effect": "Deny",
+      "Action": "s3:*",
+      "NotResource": ["arn:aws-us-gov:s3:::bucket/*", "arn:aws-us-gov:s3:::bucket"],
+      "Condition": {
+        "StringLike": {
+          "aws:PrincipalArn": "arn:aws-us-gov:iam::444455556666:role/*"
+        }
+      }
+    }
+  ]
+}`
+
+const substitutionPolicyTest2a = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Principal": {"AWS": ["111122223333", "arn:aws:iam::111122223333:user/ci"]},
+    "Action": "sts:AssumeRole"
+  }
+}`
+
+const substitutionPolicyTest2b = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Principal": {"AWS": ["arn:aws-us-gov:iam::444455556666:user/ci", "444455556666"]},
+    "Action": "sts:AssumeRole"
+  }
+}`
+
+const substitutionPolicyTest3a = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Action": "s3:GetObject",
+    "Resource": "*",
+    "Condition": {"StringEquals": {"aws:PrincipalAccount": "111122223333"}}
+  }
+}`
+
+const substitutionPolicyTest3b = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Action": "s3:GetObject",
+    "Resource": "*",
+    "Condition": {"StringEquals": {"aws:PrincipalAccount": "444455556666"}}
+  }
+}`
+
+const substitutionPolicyTest4a = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Action": "kms:Decrypt",
+    "Resource": "arn:aws:kms:us-east-1:111122223333:key/*",
+    "Condition": {"ForAnyValue:ArnLikeIfExists": {"kms:EncryptionContext:aws:lambda:FunctionArn": ["arn:aws:lambda:us-east-1:111122223333:function:*"]}}
+  }
+}`
+
+const substitutionPolicyTest4b = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Action": "kms:Decrypt",
+    "Resource": "arn:aws-cn:kms:cn-north-1:111122223333:key/*",
+    "Condition": {"ForAnyValue:ArnLikeIfExists": {"kms:EncryptionContext:aws:lambda:FunctionArn": "arn:aws-cn:lambda:cn-north-1:111122223333:function:*"}}
+  }
+}`
+
+const substitutionPolicyTest5a = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Principal": {"AWS": ["arn:aws:iam::111122223333:role/deleted", "111122223333"]},
+    "Action": "sts:AssumeRole"
+  }
+}`
+
+const substitutionPolicyTest5b = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Effect": "Allow",
+    "Principal": {"AWS": ["AROAEXAMPLEID1234567", "arn:aws:iam::111122223333:root"]},
+    "Action": "sts:AssumeRole"
+  }
+}`
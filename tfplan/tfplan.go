@@ -0,0 +1,151 @@
+// Package tfplan finds the policy documents changed by a Terraform plan
+// and tells apart changes that only reformat a policy from real ones.
+package tfplan
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+// Change is a change to a string attribute that holds a policy document
+// both before and after the change.
+type Change struct {
+	// Address is the address of the resource, such as
+	// module.app.aws_iam_role_policy.example.
+	Address string
+
+	// Attribute is the path to the attribute within the resource, such as
+	// policy or inline_policy[0].policy.
+	Attribute string
+
+	Before string
+	After  string
+
+	// Equivalent is true if the policies are equivalent, so that the
+	// change only affects formatting.
+	Equivalent bool
+}
+
+func (c Change) String() string {
+	return c.Address + "." + c.Attribute
+}
+
+// plan holds the parts of the `terraform show -json` plan representation
+// that are used.
+type plan struct {
+	FormatVersion   string `json:"format_version"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions []string    `json:"actions"`
+			Before  interface{} `json:"before"`
+			After   interface{} `json:"after"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// Analyze reads a plan in the JSON representation written by
+// `terraform show -json` and returns the changes to every string
+// attribute, including those nested in blocks, that parses as a policy
+// document with at least one statement both before and after the change.
+// URL-encoded documents are decoded. Changes are returned in the order of
+// the plan's resource changes, and by attribute path within a resource.
+//
+// Attributes whose value is unknown until apply are not compared.
+func Analyze(r io.Reader) ([]Change, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var p plan
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+	if p.FormatVersion == "" {
+		return nil, fmt.Errorf("reading plan: no format_version; expected the output of terraform show -json")
+	}
+
+	var changes []Change
+	for _, rc := range p.ResourceChanges {
+		walk(&changes, rc.Address, "", rc.Change.Before, rc.Change.After)
+	}
+	return changes, nil
+}
+
+// walk compares the values of an attribute before and after a change,
+// descending into the objects and lists of nested blocks.
+func walk(changes *[]Change, address, path string, before, after interface{}) {
+	switch before := before.(type) {
+	case map[string]interface{}:
+		after, ok := after.(map[string]interface{})
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(before))
+		for k := range before {
+			if _, ok := after[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walk(changes, address, join(path, k), before[k], after[k])
+		}
+	case []interface{}:
+		after, ok := after.([]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < len(before) && i < len(after); i++ {
+			walk(changes, address, path+"["+strconv.Itoa(i)+"]", before[i], after[i])
+		}
+	case string:
+		after, ok := after.(string)
+		if !ok || before == after || !isPolicy(before) || !isPolicy(after) {
+			return
+		}
+		equivalent, err := awspolicy.PoliciesAreEquivalent(before, after, awspolicy.WithURLDecoding())
+		*changes = append(*changes, Change{
+			Address:    address,
+			Attribute:  path,
+			Before:     before,
+			After:      after,
+			Equivalent: err == nil && equivalent,
+		})
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// join appends an object key to an attribute path, using index syntax
+// for keys, such as those of a tags map, that are not identifiers.
+func join(path, key string) string {
+	if !identifier.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isPolicy reports whether s holds a policy document. Plain strings,
+// including ones that happen to be JSON, are not policies.
+func isPolicy(s string) bool {
+	decoded, err := awspolicy.DecodePolicyDocument(s)
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(decoded), "{") {
+		return false
+	}
+	policy, err := awspolicy.ParsePolicy(decoded)
+	return err == nil && len(policy.Statements()) > 0
+}
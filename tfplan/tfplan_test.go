@@ -0,0 +1,119 @@
+package tfplan
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const planJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "aws_iam_role_policy.reformatted",
+      "change": {
+        "actions": ["update"],
+        "before": {
+          "name": "reformatted",
+          "policy": "{\"Version\":\"2012-10-17\",\"Statement\":[{\"Effect\":\"Allow\",\"Action\":[\"s3:GetObject\"],\"Resource\":\"*\"}]}"
+        },
+        "after": {
+          "name": "reformatted",
+          "policy": "{\n  \"Statement\": {\n    \"Resource\": [\"*\"],\n    \"Action\": \"s3:GetObject\",\n    \"Effect\": \"Allow\"\n  },\n  \"Version\": \"2012-10-17\"\n}"
+        }
+      }
+    },
+    {
+      "address": "module.app.aws_iam_role.example",
+      "change": {
+        "actions": ["update"],
+        "before": {
+          "assume_role_policy": "%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%7B%22Effect%22%3A%22Allow%22%2C%22Principal%22%3A%7B%22Service%22%3A%22ec2.amazonaws.com%22%7D%2C%22Action%22%3A%22sts%3AAssumeRole%22%7D%7D",
+          "inline_policy": [
+            {"name": "read", "policy": "{\"Version\":\"2012-10-17\",\"Statement\":{\"Effect\":\"Allow\",\"Action\":\"s3:GetObject\",\"Resource\":\"*\"}}"}
+          ],
+          "tags": {"Policy Source": "{\"Statement\":[]}"}
+        },
+        "after": {
+          "assume_role_policy": "{\"Version\":\"2012-10-17\",\"Statement\":{\"Effect\":\"Allow\",\"Principal\":{\"Service\":\"ec2.amazonaws.com\"},\"Action\":\"sts:AssumeRole\"}}",
+          "inline_policy": [
+            {"name": "read", "policy": "{\"Version\":\"2012-10-17\",\"Statement\":{\"Effect\":\"Allow\",\"Action\":\"s3:*\",\"Resource\":\"*\"}}"}
+          ],
+          "tags": {"Policy Source": "{\"Statement\":[{}]}"}
+        }
+      }
+    },
+    {
+      "address": "aws_iam_policy.unknown",
+      "change": {
+        "actions": ["update"],
+        "before": {"description": "old", "policy": "{\"Statement\":{\"Effect\":\"Allow\",\"Action\":\"*\",\"Resource\":\"*\"}}"},
+        "after": {"description": "new"},
+        "after_unknown": {"policy": true}
+      }
+    },
+    {
+      "address": "aws_iam_policy.created",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"policy": "{\"Statement\":{\"Effect\":\"Allow\",\"Action\":\"*\",\"Resource\":\"*\"}}"}
+      }
+    }
+  ]
+}`
+
+func TestAnalyze(t *testing.T) {
+	changes, err := Analyze(strings.NewReader(planJSON))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	type result struct {
+		change     string
+		equivalent bool
+	}
+	var got []result
+	for _, c := range changes {
+		got = append(got, result{c.String(), c.Equivalent})
+	}
+
+	want := []result{
+		{"aws_iam_role_policy.reformatted.policy", true},
+		{"module.app.aws_iam_role.example.assume_role_policy", true},
+		{"module.app.aws_iam_role.example.inline_policy[0].policy", false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bad: changes\n  Expected: %v\n       Got: %v", want, got)
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		plan  string
+		error string
+	}{
+		{
+			name:  "not JSON",
+			plan:  `resource "aws_iam_policy" "example" {}`,
+			error: "reading plan: invalid character",
+		},
+		{
+			name:  "not a plan",
+			plan:  `{"resource_changes": []}`,
+			error: "reading plan: no format_version",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := Analyze(strings.NewReader(tc.plan))
+		if err == nil || !strings.HasPrefix(err.Error(), tc.error) {
+			t.Fatalf("Bad: %s\n  Expected error: %s\n       Got: %v", tc.name, tc.error, err)
+		}
+	}
+}
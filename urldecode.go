@@ -0,0 +1,75 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxDecodeSteps bounds how many layers of URL encoding are removed.
+// Documents returned by IAM are encoded once, and a document that was
+// encoded again before being stored is encoded twice.
+const maxDecodeSteps = 3
+
+// DecodeError describes a failure to URL-decode a policy document.
+type DecodeError struct {
+	// Step is the 1-based decoding step that failed. The first step
+	// removes the outermost layer of encoding.
+	Step int
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("URL decoding step %d: %s", e.Step, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// WithURLDecoding returns an Option that detects URL-encoded policy
+// documents, such as those returned by the IAM GetPolicyVersion,
+// GetRolePolicy and GetRole APIs, and decodes them before they are
+// compared, as DecodePolicyDocument does.
+func WithURLDecoding() Option {
+	return func(o *options) {
+		o.urlDecode = true
+	}
+}
+
+// DecodePolicyDocument returns policy with any URL encoding removed.
+// A document is treated as URL-encoded if it starts with a percent sign,
+// which a JSON document never does. Documents that were encoded more than
+// once are decoded until they are plain JSON, and documents that are not
+// encoded are returned unchanged.
+//
+// A failure is reported as a *DecodeError naming the step that failed.
+func DecodePolicyDocument(policy string) (string, error) {
+	for step := 1; isURLEncoded(policy); step++ {
+		if step > maxDecodeSteps {
+			return "", &DecodeError{Step: step, Err: fmt.Errorf("still URL-encoded after %d decoding steps", maxDecodeSteps)}
+		}
+
+		decoded, err := url.QueryUnescape(strings.TrimSpace(policy))
+		if err != nil {
+			var escapeErr url.EscapeError
+			if errors.As(err, &escapeErr) {
+				err = fmt.Errorf("invalid escape %q", string(escapeErr))
+			}
+			return "", &DecodeError{Step: step, Err: err}
+		}
+		policy = decoded
+	}
+	return policy, nil
+}
+
+// isURLEncoded reports whether policy looks like a URL-encoded document.
+func isURLEncoded(policy string) bool {
+	return strings.HasPrefix(strings.TrimSpace(policy), "%")
+}
@@ -0,0 +1,97 @@
+package awspolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestDecodePolicyDocument(t *testing.T) {
+	encoded := url.QueryEscape(policyTest2a)
+
+	cases := []struct {
+		name   string
+		policy string
+		step   int
+		err    string
+	}{
+		{
+			name:   "Plain",
+			policy: policyTest2a,
+		},
+		{
+			name:   "URL-encoded",
+			policy: encoded,
+		},
+		{
+			name:   "Double-encoded",
+			policy: "\n" + url.QueryEscape(encoded),
+		},
+		{
+			name:   "Invalid escape",
+			policy: "%7B%22Version%22%3A%zz",
+			step:   1,
+			err:    `URL decoding step 1: invalid escape "%zz"`,
+		},
+		{
+			name:   "Invalid escape in second layer",
+			policy: url.QueryEscape("%7B%2"),
+			step:   2,
+			err:    `URL decoding step 2: invalid escape "%2"`,
+		},
+		{
+			name:   "Too many layers",
+			policy: url.QueryEscape(url.QueryEscape(url.QueryEscape(encoded))),
+			step:   4,
+			err:    "URL decoding step 4: still URL-encoded after 3 decoding steps",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := DecodePolicyDocument(tc.policy)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if decoded != policyTest2a {
+					t.Fatalf("Bad: %s\n  Expected: %s\n       Got: %s\n", tc.name, policyTest2a, decoded)
+				}
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Expected *DecodeError, got %T: %v", err, err)
+			}
+			if decodeErr.Step != tc.step || err.Error() != tc.err {
+				t.Fatalf("Bad error\n  Expected: %s\n       Got: %s", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestPolicyEquivalenceURLDecoding(t *testing.T) {
+	encoded := url.QueryEscape(policyTest2b)
+
+	if _, err := PoliciesAreEquivalent(policyTest2a, encoded); err == nil {
+		t.Fatal("Expected error without WithURLDecoding, none produced")
+	}
+
+	equivalent, err := PoliciesAreEquivalent(policyTest2a, encoded, WithURLDecoding())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !equivalent {
+		t.Fatal("Expected URL-encoded policy to be equivalent")
+	}
+
+	_, err = PoliciesAreEquivalent(policyTest2a, "%7B%zz", WithURLDecoding())
+	if want := `decoding policy 2: URL decoding step 1: invalid escape "%zz"`; err == nil || err.Error() != want {
+		t.Fatalf("Bad error\n  Expected: %s\n       Got: %v", want, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package yamlpolicy parses AWS policy documents written in YAML, such as
+// those embedded in CloudFormation templates, so that they can be
+// compared with policies written in any other format.
+package yamlpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+	"github.com/hashicorp/awspolicyequivalence/source"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format name reported in a *awspolicy.ParseError.
+const Format = "YAML"
+
+// ParsePolicy parses a policy document written in YAML. The source must
+// hold a single YAML document. Problems with the document, including YAML
+// syntax errors and tags such as CloudFormation's !Sub that can't be
+// evaluated, are reported as a *awspolicy.ParseError with Format set to
+// "YAML" and the position of the offending value in src.
+func ParsePolicy(src []byte) (*awspolicy.Policy, error) {
+	p := &parser{src: src, doc: &source.Document{Format: Format}}
+
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	var root yaml.Node
+	if err := dec.Decode(&root); err != nil {
+		if err == io.EOF {
+			return awspolicy.ParsePolicy("")
+		}
+		return nil, p.syntaxError(err)
+	}
+
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		if err != nil {
+			return nil, p.syntaxError(err)
+		}
+		return nil, p.doc.SyntaxError(p.pos(&extra), errors.New("expected a single YAML document"))
+	}
+
+	return ParseNode(src, &root)
+}
+
+// ParseNode parses a policy document held in a node of YAML already
+// decoded from src, such as a property of a larger document. Problems are
+// reported as ParsePolicy reports them, with positions in src and paths
+// relative to the node.
+func ParseNode(src []byte, n *yaml.Node) (*awspolicy.Policy, error) {
+	p := &parser{src: src, doc: &source.Document{Format: Format}}
+
+	v, err := p.value(n, "")
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.Policy(v)
+}
+
+type parser struct {
+	src []byte
+	doc *source.Document
+}
+
+func (p *parser) pos(n *yaml.Node) source.Pos {
+	return source.PosOf(p.src, n.Line, n.Column)
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// syntaxError converts an error from the YAML decoder, which reports only
+// a line number, into a *awspolicy.ParseError.
+func (p *parser) syntaxError(err error) error {
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return p.doc.SyntaxError(source.PosOf(p.src, line, 0), errors.New(m[2]))
+	}
+	return p.doc.SyntaxError(source.PosOf(p.src, 1, 0), err)
+}
+
+// value converts a YAML node into a JSON value.
+func (p *parser) value(n *yaml.Node, path string) (*source.Value, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return &source.Value{Pos: p.pos(n), Kind: source.Null}, nil
+		}
+		return p.value(n.Content[0], path)
+	case yaml.AliasNode:
+		return p.value(n.Alias, path)
+	case yaml.MappingNode:
+		return p.mapping(n, path)
+	case yaml.SequenceNode:
+		v := &source.Value{Pos: p.pos(n), Kind: source.Array}
+		for i, item := range n.Content {
+			elem, err := p.value(item, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			v.Elems = append(v.Elems, elem)
+		}
+		return v, nil
+	case yaml.ScalarNode:
+		return p.scalar(n, path)
+	default:
+		return nil, p.doc.Errorf(p.pos(n), path, "unexpected YAML node")
+	}
+}
+
+func (p *parser) mapping(n *yaml.Node, path string) (*source.Value, error) {
+	v := &source.Value{Pos: p.pos(n), Kind: source.Object}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode, valueNode := n.Content[i], n.Content[i+1]
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, p.doc.Errorf(p.pos(keyNode), path, "mapping keys must be scalars")
+		}
+		if keyNode.Tag == "!!merge" {
+			return nil, p.doc.Errorf(p.pos(keyNode), path, "merge keys are not supported")
+		}
+
+		key := keyNode.Value
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		elem, err := p.value(valueNode, child)
+		if err != nil {
+			return nil, err
+		}
+		v.Keys = append(v.Keys, key)
+		v.Elems = append(v.Elems, elem)
+	}
+	return v, nil
+}
+
+func (p *parser) scalar(n *yaml.Node, path string) (*source.Value, error) {
+	v := &source.Value{Pos: p.pos(n)}
+
+	switch n.ShortTag() {
+	case "!!str", "!!binary", "!!timestamp":
+		v.Kind, v.Text = source.String, n.Value
+	case "!!null":
+		v.Kind = source.Null
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, p.doc.Errorf(v.Pos, path, "invalid boolean %q", n.Value)
+		}
+		v.Kind, v.Text = source.Bool, strconv.FormatBool(b)
+	case "!!int", "!!float":
+		text, err := number(n)
+		if err != nil {
+			return nil, p.doc.Errorf(v.Pos, path, "%s", err)
+		}
+		v.Kind, v.Text = source.Number, text
+	default:
+		return nil, p.doc.Errorf(v.Pos, path, "unsupported tag %s", n.Tag)
+	}
+	return v, nil
+}
+
+// number returns the JSON literal for a YAML number. Literals that are
+// already valid JSON keep their text, so that no precision is lost.
+func number(n *yaml.Node) (string, error) {
+	var literal json.Number
+	if json.Unmarshal([]byte(n.Value), &literal) == nil {
+		return literal.String(), nil
+	}
+
+	if n.ShortTag() == "!!int" {
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		var u uint64
+		if err := n.Decode(&u); err == nil {
+			return strconv.FormatUint(u, 10), nil
+		}
+		return "", errors.New("invalid integer " + strconv.Quote(n.Value))
+	}
+
+	var f float64
+	if err := n.Decode(&f); err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", errors.New("number " + strconv.Quote(n.Value) + " can't be represented in JSON")
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
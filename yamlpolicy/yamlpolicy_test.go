@@ -0,0 +1,146 @@
+package yamlpolicy
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+import (
+	"errors"
+	"testing"
+
+	awspolicy "github.com/hashicorp/awspolicyequivalence"
+)
+
+const jsonPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "Read",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:root"},
+      "Action": ["s3:GetObject", "s3:ListBucket"],
+      "Resource": "arn:aws:s3:::bucket/*",
+      "Condition": {"NumericLessThan": {"s3:max-keys": "10"}, "Bool": {"aws:SecureTransport": "true"}}
+    }
+  ]
+}`
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "Block style",
+			yaml: `
+Version: 2012-10-17
+Statement:
+  - Sid: Read
+    Effect: Allow
+    Principal:
+      AWS: 123456789012
+    Action:
+      - s3:GetObject
+      - s3:ListBucket
+    Resource: arn:aws:s3:::bucket/*
+    Condition:
+      NumericLessThan:
+        s3:max-keys: 10
+      Bool:
+        aws:SecureTransport: true
+`,
+		},
+		{
+			name: "Flow style with anchors",
+			yaml: `
+actions: &actions [s3:ListBucket, s3:GetObject]
+Version: "2012-10-17"
+Statement: {Sid: Read, Effect: Allow, Principal: {AWS: "arn:aws:iam::123456789012:root"}, Action: *actions, Resource: [arn:aws:s3:::bucket/*], Condition: {NumericLessThan: {"s3:max-keys": 1e1}, Bool: {"aws:SecureTransport": "true"}}}
+`,
+		},
+	}
+
+	expected, err := awspolicy.ParsePolicy(jsonPolicy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParsePolicy([]byte(tc.yaml))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !policy.EquivalentTo(expected) {
+				got, _ := policy.Marshal()
+				t.Fatalf("Bad: %s\n  Expected equivalent to JSON policy, got: %s", tc.name, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		yaml   string
+		err    string
+		line   int
+		column int
+		syntax bool
+	}{
+		{
+			name:   "Invalid YAML",
+			yaml:   "Version: 2012-10-17\nStatement: [\n",
+			err:    "YAML: did not find expected node content at line 2",
+			line:   2,
+			syntax: true,
+		},
+		{
+			name:   "Multiple documents",
+			yaml:   "Version: 2012-10-17\n---\nVersion: 2008-10-17\n",
+			err:    "YAML: expected a single YAML document at line 2, column 1",
+			line:   2,
+			column: 1,
+			syntax: true,
+		},
+		{
+			name: "Intrinsic function",
+			yaml: `Version: 2012-10-17
+Statement:
+  - Effect: Allow
+    Resource: !Sub arn:aws:s3:::${Bucket}/*
+`,
+			err:    "YAML: Statement[0].Resource: unsupported tag !Sub at line 4, column 15",
+			line:   4,
+			column: 15,
+		},
+		{
+			name: "Policy error",
+			yaml: `Version: 2012-10-17
+Statement:
+  - Effect: Allow
+  - Effect: [Deny]
+`,
+			err:    "YAML: Statement[1].Effect: expected string, found array at line 4, column 13",
+			line:   4,
+			column: 13,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tc.yaml))
+
+			var parseErr *awspolicy.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *awspolicy.ParseError, got %T: %v", err, err)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("Bad error\n  Expected: %s\n       Got: %s", tc.err, err)
+			}
+			if parseErr.Format != Format || parseErr.Line != tc.line || parseErr.Column != tc.column || parseErr.Syntax != tc.syntax {
+				t.Errorf("Bad error details: %+v", parseErr)
+			}
+		})
+	}
+}